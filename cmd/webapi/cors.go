@@ -16,9 +16,9 @@ func applyCORSHandler(h http.Handler) http.Handler {
 			"Authorization",
 			"authorization",
 			"content-type",
+			"Last-Event-ID",
 		}),
-		handlers.AllowCredentials(),
-		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS", "DELETE", "PUT"}),
+		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS", "DELETE", "PUT", "PATCH"}),
 		handlers.AllowedOrigins([]string{"*"}),
 		handlers.MaxAge(1),
 	)(h)
@@ -22,11 +22,31 @@ type WebAPIConfiguration struct {
 		ReadTimeout     time.Duration `conf:"default:5s"`
 		WriteTimeout    time.Duration `conf:"default:5s"`
 		ShutdownTimeout time.Duration `conf:"default:5s"`
+		// AllowedOrigins are the origins of the web pages allowed to open the WebSocket event stream, "*" allowing
+		// any: the web UI served by nginx, the Vite development server and the Vite preview. The rest of the API is
+		// open to any origin.
+		AllowedOrigins []string `conf:"default:http://localhost;http://localhost:5173;http://localhost:4173"`
 	}
 	Debug bool
 	DB    struct {
 		Filename string `conf:"default:/tmp/decaf.db"`
 	}
+	Auth struct {
+		// RequireCredentials forces every account to log in with a password. When false, username-only login is
+		// still accepted for accounts without a password (useful for demos).
+		RequireCredentials bool `conf:"default:false"`
+	}
+	Messages struct {
+		// EditWindow is how long after sending a message its sender can still edit it
+		EditWindow time.Duration `conf:"default:15m"`
+	}
+	Uploads struct {
+		// MaxSize is the largest image or file that can be uploaded, in bytes
+		MaxSize int64 `conf:"default:10485760"`
+		// ImageTypes and FileTypes are the MIME types accepted for images and for other attachments
+		ImageTypes []string `conf:"default:image/jpeg;image/png;image/gif;image/webp"`
+		FileTypes  []string `conf:"default:application/pdf;text/plain;text/csv;application/zip;application/msword;application/vnd.openxmlformats-officedocument.wordprocessingml.document;application/vnd.ms-excel;application/vnd.openxmlformats-officedocument.spreadsheetml.sheet;application/vnd.ms-powerpoint;application/vnd.openxmlformats-officedocument.presentationml.presentation;application/vnd.oasis.opendocument.text;application/vnd.oasis.opendocument.spreadsheet;application/vnd.oasis.opendocument.presentation"`
+	}
 }
 
 // loadConfiguration creates a WebAPIConfiguration starting from flags, environment variables and configuration file.
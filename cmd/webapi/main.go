@@ -112,8 +112,14 @@ func run() error {
 
 	// Create the API router
 	apirouter, err := api.New(api.Config{
-		Logger:   logger,
-		Database: db,
+		Logger:             logger,
+		Database:           db,
+		RequireCredentials: cfg.Auth.RequireCredentials,
+		EditWindow:         cfg.Messages.EditWindow,
+		MaxUploadSize:      cfg.Uploads.MaxSize,
+		AllowedImageTypes:  cfg.Uploads.ImageTypes,
+		AllowedFileTypes:   cfg.Uploads.FileTypes,
+		AllowedOrigins:     cfg.Web.AllowedOrigins,
 	})
 	if err != nil {
 		logger.WithError(err).Error("error creating the API server instance")
@@ -8,11 +8,26 @@ import (
 func (rt *_router) Handler() http.Handler {
 	// Register routes
 	rt.router.POST("/session", rt.wrap(rt.doLogin))
+	rt.router.DELETE("/session", rt.wrap(rt.idVerifierMiddleware(rt.doLogout)))
 	rt.router.POST("/upload", rt.wrap(rt.uploadImage))
+	rt.router.POST("/files", rt.wrap(rt.idVerifierMiddleware(rt.uploadFile)))
+	rt.router.GET("/files/:fileId", rt.wrap(rt.idVerifierMiddleware(rt.downloadFile)))
+	rt.router.POST("/voice_notes", rt.wrap(rt.idVerifierMiddleware(rt.uploadVoiceNote)))
 	rt.router.GET("/users", rt.wrap(rt.idVerifierMiddleware(rt.getUsers)))
 
 	rt.router.PUT("/me/username", rt.wrap(rt.idVerifierMiddleware(rt.setMyUsername)))
 	rt.router.PUT("/me/photo", rt.wrap(rt.idVerifierMiddleware(rt.setMyPhoto)))
+	rt.router.PUT("/me/password", rt.wrap(rt.idVerifierMiddleware(rt.setMyPassword)))
+	rt.router.GET("/me/sessions", rt.wrap(rt.idVerifierMiddleware(rt.getMySessions)))
+	rt.router.DELETE("/me/sessions", rt.wrap(rt.idVerifierMiddleware(rt.revokeAllSessions)))
+	rt.router.DELETE("/me/sessions/:sessionId", rt.wrap(rt.idVerifierMiddleware(rt.revokeSession)))
+	rt.router.GET("/me/starred", rt.wrap(rt.idVerifierMiddleware(rt.getMyStarredMessages)))
+	rt.router.POST("/me/starred/:messageId", rt.wrap(rt.idVerifierMiddleware(rt.starMessage)))
+	rt.router.DELETE("/me/starred/:messageId", rt.wrap(rt.idVerifierMiddleware(rt.unstarMessage)))
+	rt.router.GET("/me/mentions", rt.wrap(rt.idVerifierMiddleware(rt.getMyMentions)))
+	rt.router.GET("/me/scheduled", rt.wrap(rt.idVerifierMiddleware(rt.getMyScheduledMessages)))
+	rt.router.PATCH("/me/scheduled/:scheduledMessageId", rt.wrap(rt.idVerifierMiddleware(rt.rescheduleMessage)))
+	rt.router.DELETE("/me/scheduled/:scheduledMessageId", rt.wrap(rt.idVerifierMiddleware(rt.cancelScheduledMessage)))
 
 	rt.router.POST("/conversations", rt.wrap(rt.idVerifierMiddleware(rt.createConversation)))
 	rt.router.GET("/conversations", rt.wrap(rt.idVerifierMiddleware(rt.getMyConversations)))
@@ -20,15 +35,44 @@ func (rt *_router) Handler() http.Handler {
 
 	rt.router.PUT("/conversations/:conversationId/name", rt.wrap(rt.idVerifierMiddleware(rt.setGroupName)))
 	rt.router.PUT("/conversations/:conversationId/photo", rt.wrap(rt.idVerifierMiddleware(rt.setGroupPhoto)))
+	rt.router.PUT("/conversations/:conversationId/message_ttl", rt.wrap(rt.idVerifierMiddleware(rt.setMessageTtl)))
 	rt.router.POST("/conversations/:conversationId/participants", rt.wrap(rt.idVerifierMiddleware(rt.addToGroup)))
 	rt.router.DELETE("/conversations/:conversationId/participants", rt.wrap(rt.idVerifierMiddleware(rt.leaveGroup)))
+	rt.router.DELETE("/conversations/:conversationId/participants/:userId", rt.wrap(rt.idVerifierMiddleware(rt.removeFromGroup)))
+	rt.router.GET("/conversations/:conversationId/bans", rt.wrap(rt.idVerifierMiddleware(rt.getGroupBans)))
+	rt.router.DELETE("/conversations/:conversationId/bans/:userId", rt.wrap(rt.idVerifierMiddleware(rt.liftBan)))
+	rt.router.POST("/conversations/:conversationId/invites", rt.wrap(rt.idVerifierMiddleware(rt.createInvite)))
+	rt.router.GET("/conversations/:conversationId/invites", rt.wrap(rt.idVerifierMiddleware(rt.getInvites)))
+	rt.router.DELETE("/conversations/:conversationId/invites/:code", rt.wrap(rt.idVerifierMiddleware(rt.revokeInvite)))
+	rt.router.POST("/invites/:code/join", rt.wrap(rt.idVerifierMiddleware(rt.joinWithInvite)))
+	rt.router.GET("/conversations/:conversationId/join_requests", rt.wrap(rt.idVerifierMiddleware(rt.getJoinRequests)))
+	rt.router.POST("/conversations/:conversationId/join_requests/:userId/approve", rt.wrap(rt.idVerifierMiddleware(rt.approveJoinRequest)))
+	rt.router.POST("/conversations/:conversationId/join_requests/:userId/deny", rt.wrap(rt.idVerifierMiddleware(rt.denyJoinRequest)))
+	rt.router.PUT("/conversations/:conversationId/admins/:userId", rt.wrap(rt.idVerifierMiddleware(rt.promoteAdmin)))
+	rt.router.DELETE("/conversations/:conversationId/admins/:userId", rt.wrap(rt.idVerifierMiddleware(rt.demoteAdmin)))
+	rt.router.PUT("/conversations/:conversationId/permissions", rt.wrap(rt.idVerifierMiddleware(rt.setGroupPermissions)))
+	rt.router.PUT("/conversations/:conversationId/history_visibility", rt.wrap(rt.idVerifierMiddleware(rt.setHistoryVisibility)))
 
+	rt.router.GET("/conversations/:conversationId/messages", rt.wrap(rt.idVerifierMiddleware(rt.getConversationMessages)))
 	rt.router.POST("/conversations/:conversationId/messages", rt.wrap(rt.idVerifierMiddleware(rt.sendMessage)))
+	rt.router.GET("/conversations/:conversationId/messages/:messageId/thread", rt.wrap(rt.idVerifierMiddleware(rt.getMessageThread)))
+	rt.router.GET("/conversations/:conversationId/messages/:messageId/context", rt.wrap(rt.idVerifierMiddleware(rt.getMessageContext)))
+	rt.router.PATCH("/conversations/:conversationId/messages/:messageId", rt.wrap(rt.idVerifierMiddleware(rt.editMessage)))
 	rt.router.DELETE("/conversations/:conversationId/messages/:messageId", rt.wrap(rt.idVerifierMiddleware(rt.deleteMessage)))
+	rt.router.GET("/conversations/:conversationId/messages/:messageId/revisions", rt.wrap(rt.idVerifierMiddleware(rt.getMessageRevisions)))
+	rt.router.GET("/search/messages", rt.wrap(rt.idVerifierMiddleware(rt.searchMessages)))
 	rt.router.POST("/conversations/:conversationId/forwarded_messages", rt.wrap(rt.idVerifierMiddleware(rt.forwardMessage)))
 
+	rt.router.POST("/conversations/:conversationId/pins/:messageId", rt.wrap(rt.idVerifierMiddleware(rt.pinMessage)))
+	rt.router.DELETE("/conversations/:conversationId/pins/:messageId", rt.wrap(rt.idVerifierMiddleware(rt.unpinMessage)))
 	rt.router.POST("/conversations/:conversationId/messages/:messageId/reactions", rt.wrap(rt.idVerifierMiddleware(rt.commentMessage)))
 	rt.router.DELETE("/conversations/:conversationId/messages/:messageId/reactions", rt.wrap(rt.idVerifierMiddleware(rt.uncommentMessage)))
+	rt.router.GET("/conversations/:conversationId/messages/:messageId/votes", rt.wrap(rt.idVerifierMiddleware(rt.getPollVotes)))
+	rt.router.PUT("/conversations/:conversationId/messages/:messageId/votes", rt.wrap(rt.idVerifierMiddleware(rt.votePoll)))
+	rt.router.GET("/events", rt.wrap(rt.streamVerifierMiddleware(rt.getEvents)))
+	rt.router.GET("/events/sse", rt.wrap(rt.streamVerifierMiddleware(rt.getEventStream)))
+	rt.router.GET("/sync", rt.wrap(rt.idVerifierMiddleware(rt.getSync)))
+
 	rt.router.ServeFiles("/uploads/*filepath", http.Dir("./uploads"))
 	// Special routes
 	rt.router.GET("/liveness", rt.liveness)
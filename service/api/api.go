@@ -39,8 +39,12 @@ package api
 import (
 	"errors"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/Reewd/WASAproject/service/api/constraints"
 	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
 )
@@ -52,6 +56,22 @@ type Config struct {
 
 	// Database is the instance of database.AppDatabase where data are saved
 	Database database.AppDatabase
+
+	// RequireCredentials rejects username-only logins: every account must log in with a password
+	RequireCredentials bool
+
+	// EditWindow is how long after sending a message its sender can still edit it
+	EditWindow time.Duration
+
+	// MaxUploadSize is the largest image or file that can be uploaded, in bytes
+	MaxUploadSize int64
+
+	// AllowedImageTypes and AllowedFileTypes are the MIME types accepted for uploaded images and files
+	AllowedImageTypes []string
+	AllowedFileTypes  []string
+
+	// AllowedOrigins are the origins of the web pages allowed to open the WebSocket event stream; "*" allows any
+	AllowedOrigins []string
 }
 
 // Router is the package API interface representing an API handler builder
@@ -72,6 +92,12 @@ func New(cfg Config) (Router, error) {
 	if cfg.Database == nil {
 		return nil, errors.New("database is required")
 	}
+	if cfg.EditWindow <= 0 {
+		return nil, errors.New("edit window must be positive")
+	}
+	if cfg.MaxUploadSize <= 0 {
+		return nil, errors.New("max upload size must be positive")
+	}
 
 	// Create a new router where we will register HTTP endpoints. The server will pass requests to this router to be
 	// handled.
@@ -79,11 +105,27 @@ func New(cfg Config) (Router, error) {
 	router.RedirectTrailingSlash = false
 	router.RedirectFixedPath = false
 
-	return &_router{
-		router:     router,
-		baseLogger: cfg.Logger,
-		db:         cfg.Database,
-	}, nil
+	rt := &_router{
+		router:             router,
+		baseLogger:         cfg.Logger,
+		db:                 cfg.Database,
+		requireCredentials: cfg.RequireCredentials,
+		editWindow:         cfg.EditWindow,
+		maxUploadSize:      cfg.MaxUploadSize,
+		allowedImageTypes:  cfg.AllowedImageTypes,
+		allowedFileTypes:   cfg.AllowedFileTypes,
+		allowedOrigins:     cfg.AllowedOrigins,
+		bus:                events.NewBus(),
+		stop:               make(chan struct{}),
+	}
+
+	// Start the background tasks; Close stops them
+	rt.runPeriodically(constraints.SchedulerInterval, rt.sendScheduledMessages)
+	rt.runPeriodically(constraints.ReaperInterval, rt.removeExpiredMessages)
+	rt.runPeriodically(constraints.ReaperInterval, rt.removeOldEvents)
+	rt.runPeriodically(constraints.ReaperInterval, rt.removeOldChanges)
+
+	return rt, nil
 }
 
 type _router struct {
@@ -94,4 +136,25 @@ type _router struct {
 	baseLogger logrus.FieldLogger
 
 	db database.AppDatabase
+
+	requireCredentials bool
+
+	editWindow time.Duration
+
+	maxUploadSize     int64
+	allowedImageTypes []string
+	allowedFileTypes  []string
+
+	allowedOrigins []string
+
+	// bus delivers real-time events to the clients connected to the event stream
+	bus *events.Bus
+
+	// publishMu makes storing an event and sending it one step, so that every user receives their events in the order
+	// of their sequence numbers
+	publishMu sync.Mutex
+
+	// stop is closed to stop the background tasks, and background waits for them to return
+	stop       chan struct{}
+	background sync.WaitGroup
 }
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/events"
+)
+
+// runPeriodically calls task every interval in a background goroutine, until the router is closed.
+func (rt *_router) runPeriodically(interval time.Duration, task func()) {
+	rt.background.Add(1)
+	go func() {
+		defer rt.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-rt.stop:
+				return
+			case <-ticker.C:
+				task()
+			}
+		}
+	}()
+}
+
+// sendScheduledMessages sends the scheduled messages whose time has come, as if their senders sent them now. Messages
+// whose sender has left the conversation in the meantime are dropped, and messages that fail to be stored stay
+// scheduled until the next run.
+func (rt *_router) sendScheduledMessages() {
+	logger := rt.baseLogger.WithField("task", "scheduler")
+
+	due, err := rt.db.GetDueScheduledMessages(constraints.SchedulerBatchSize)
+	if err != nil {
+		logger.WithError(err).Error("Failed to retrieve due scheduled messages")
+		return
+	}
+
+	for _, sm := range due {
+		messageId, sent, err := rt.db.SendScheduledMessage(sm.ScheduledId)
+		if err != nil {
+			logger.WithError(err).WithField("scheduledMessageId", sm.ScheduledId).Error("Failed to send scheduled message")
+			continue
+		}
+		if !sent {
+			continue
+		}
+
+		ctx := reqcontext.RequestContext{Logger: logger, UserID: sm.SenderId}
+		if _, err := rt.announceMessage(ctx, sm.ConversationId, messageId, sm.Text, sm.ThreadRootId); err != nil {
+			logger.WithError(err).Error("Failed to announce scheduled message")
+		}
+	}
+}
+
+// removeExpiredMessages purges the messages of disappearing conversations whose time is up, together with the photos and
+// files no longer used by anything else, and tells the participants to drop them.
+func (rt *_router) removeExpiredMessages() {
+	logger := rt.baseLogger.WithField("task", "reaper")
+
+	expired, paths, err := rt.db.RemoveExpiredMessages(constraints.ReaperBatchSize)
+	if err != nil {
+		logger.WithError(err).Error("Failed to remove expired messages")
+		return
+	}
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.WithError(err).WithField("path", path).Error("Failed to remove attachment of expired message")
+		}
+	}
+
+	ctx := reqcontext.RequestContext{Logger: logger}
+	for _, msg := range expired {
+		rt.publishToConversation(ctx, msg.ConversationId, events.MessageDeleted, dto.MessageDeletedEvent{MessageId: msg.MessageId, ForEveryone: true})
+	}
+}
+
+// removeOldEvents purges the stored events that are too old to be replayed to reconnecting clients.
+func (rt *_router) removeOldEvents() {
+	if err := rt.db.RemoveOldUserEvents(constraints.EventRetention); err != nil {
+		rt.baseLogger.WithField("task", "reaper").WithError(err).Error("Failed to remove old events")
+	}
+}
+
+// removeOldChanges prunes the change log. Clients whose cursor is older than what is left have to load the full state
+// again.
+func (rt *_router) removeOldChanges() {
+	if err := rt.db.RemoveOldChanges(constraints.ChangeRetention); err != nil {
+		rt.baseLogger.WithField("task", "reaper").WithError(err).Error("Failed to remove old changes")
+	}
+}
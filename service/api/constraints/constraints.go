@@ -1,5 +1,7 @@
 package constraints
 
+import "time"
+
 //TODO: Make sure all constraints are enforced in the backend
 
 const MaxParticipants = 1000
@@ -13,11 +15,52 @@ const MinUsernameLength = 3
 const MaxMessageLength = 65536
 const MinMessageLength = 1
 
-const MaxFileSize = 10 * 1024 * 1024
+const MaxFilenameLength = 255
+
+const MaxVoiceNoteDuration = 15 * time.Minute
+const VoiceWaveformSamples = 64
+
+const SessionDuration = 30 * 24 * time.Hour
+const SessionTokenBytes = 32
+const MaxDeviceLabelLength = 64
+
+const DefaultMessagePageSize = 50
+const MaxMessagePageSize = 100
+const DefaultContextRadius = 25
+const MaxContextRadius = 50
+
+const MaxPinnedMessages = 5
+
+const InviteCodeBytes = 12
+const MaxInviteDuration = 365 * 24 * time.Hour
+const MaxInviteUses = MaxParticipants
+
+const MinPollOptions = 2
+const MaxPollOptions = 12
+const MaxPollQuestionLength = 300
+const MaxPollOptionLength = 100
+const MaxPollDuration = 365 * 24 * time.Hour
+
+const MaxSearchQueryLength = 256
+const DefaultSearchPageSize = 20
+const MaxSearchPageSize = 50
+
+const MinMessageTtl = time.Minute
+const MaxMessageTtl = 365 * 24 * time.Hour
+const ReaperInterval = 30 * time.Second
+const ReaperBatchSize = 500
+
+const MaxScheduleAhead = 365 * 24 * time.Hour
+const SchedulerInterval = 5 * time.Second
+const SchedulerBatchSize = 100
+
+const SyncBatchSize = 500
+const ChangeRetention = 30 * 24 * time.Hour
+
+const EventRetention = 7 * 24 * time.Hour
+const EventReplayBatchSize = 500
 
-var AllowedMimeTypes = []string{
-	"image/jpeg",
-	"image/png",
-	"image/gif",
-	"image/webp",
-}
+const MinPasswordLength = 8
+const MaxPasswordLength = 128
+const MaxFailedLogins = 5
+const LoginLockoutDuration = 15 * time.Minute
@@ -6,11 +6,14 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/Reewd/WASAproject/service/api/constraints"
 	"github.com/Reewd/WASAproject/service/api/dto"
 	"github.com/Reewd/WASAproject/service/api/helpers"
 	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -59,6 +62,19 @@ func (rt *_router) createGroup(w http.ResponseWriter, ctx reqcontext.RequestCont
 		return
 	}
 
+	// The creator owns the group
+	if _, err := rt.db.SetParticipantRole(conversationId, ctx.UserID, database.RoleOwner); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to set group owner")
+		return
+	}
+
+	permissions, err := rt.db.GetGroupPermissions(conversationId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve group permissions")
+		return
+	}
+	dtoPermissions := helpers.ConvertGroupPermissions(permissions)
+
 	// Retrieve participants
 	database_participants, err := rt.db.GetParticipants(conversationId)
 	if err != nil {
@@ -68,14 +84,18 @@ func (rt *_router) createGroup(w http.ResponseWriter, ctx reqcontext.RequestCont
 
 	participants := helpers.ConvertUsers(database_participants)
 
+	rt.publishToConversation(ctx, conversationId, events.ParticipantAdded, dto.ParticipantAddedEvent{Participants: participants})
+
 	// Respond with the created conversation
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(dto.Chat{
-		ConversationId: conversationId,
-		Name:           req.Name,
-		Participants:   participants,
-		IsGroup:        req.IsGroup,
-		Photo:          Photo,
+		ConversationId:    conversationId,
+		Name:              req.Name,
+		Participants:      participants,
+		IsGroup:           req.IsGroup,
+		Photo:             Photo,
+		Permissions:       &dtoPermissions,
+		HistoryVisibility: database.HistoryFull,
 	})
 
 	if err != nil {
@@ -96,12 +116,14 @@ func (rt *_router) createPrivateConversation(w http.ResponseWriter, ctx reqconte
 		return
 	}
 
+	created := false
 	if conversationId == 0 {
 		conversationId, err = rt.db.InsertConversation(req.Name, req.Participants, req.IsGroup, nil)
 		if err != nil {
 			helpers.HandleInternalServerError(ctx, w, err, "Failed to create conversation")
 			return
 		}
+		created = true
 	}
 
 	database_participants, err := rt.db.GetParticipants(conversationId)
@@ -112,6 +134,10 @@ func (rt *_router) createPrivateConversation(w http.ResponseWriter, ctx reqconte
 
 	participants := helpers.ConvertUsers(database_participants)
 
+	if created {
+		rt.publishToConversation(ctx, conversationId, events.ParticipantAdded, dto.ParticipantAddedEvent{Participants: participants})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(dto.Chat{
 		ConversationId: conversationId,
@@ -135,12 +161,18 @@ func (rt *_router) getMyConversations(w http.ResponseWriter, r *http.Request, ps
 
 	var conversations = make([]dto.ConversationPreview, 0, len(databaseConversations))
 	for _, dbConv := range databaseConversations {
-		databaseLastMessage, err := rt.db.GetLastMessage(dbConv.ConversationId)
+		databaseLastMessage, err := rt.db.GetLastMessage(dbConv.ConversationId, ctx.UserID)
 		if err != nil {
 			helpers.HandleInternalServerError(ctx, w, err, fmt.Sprintf("Failed to retrieve last message for conversation %d", dbConv.ConversationId))
 			return
 		}
 
+		unreadCount, err := rt.db.CountUnread(dbConv.ConversationId, ctx.UserID)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, fmt.Sprintf("Failed to count unread messages for conversation %d", dbConv.ConversationId))
+			return
+		}
+
 		var lastMessage *dto.SentMessage
 		if databaseLastMessage != nil {
 			msg := helpers.ConvertToSentMessage(*databaseLastMessage)
@@ -154,6 +186,8 @@ func (rt *_router) getMyConversations(w http.ResponseWriter, r *http.Request, ps
 			IsGroup:        dbConv.IsGroup,
 			Photo:          helpers.ConvertPhoto(dbConv.Photo),
 			LastMessage:    lastMessage,
+			MessageTtl:     dbConv.MessageTtl,
+			UnreadCount:    unreadCount,
 		})
 
 	}
@@ -177,12 +211,16 @@ func (rt *_router) getMyConversations(w http.ResponseWriter, r *http.Request, ps
 		"conversations": conversations, // Wrap the conversations array with a key
 	}
 
-	err = rt.db.InsertDelivered(ctx.UserID)
+	deliveredConversationIds, err := rt.db.InsertDelivered(ctx.UserID)
 	if err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to insert delivered status")
 		return
 	}
 
+	for _, deliveredConversationId := range deliveredConversationIds {
+		rt.publishToConversation(ctx, deliveredConversationId, events.StatusUpdated, dto.StatusUpdatedEvent{UserId: ctx.UserID, Status: "delivered"})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
@@ -239,18 +277,28 @@ func (rt *_router) getConversation(w http.ResponseWriter, r *http.Request, ps ht
 		return
 	}
 
-	database_chat, err := rt.db.GetChat(conversationId)
+	database_chat, hasMore, err := rt.db.GetChatPage(conversationId, ctx.UserID, nil, nil, constraints.DefaultMessagePageSize)
 	if err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve chat messages")
 		return
 	}
 
-	err = rt.db.InsertRead(conversationId, ctx.UserID)
+	pins, err := rt.db.GetPins(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve pinned messages")
+		return
+	}
+
+	read, err := rt.db.InsertRead(conversationId, ctx.UserID)
 	if err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to insert read status")
 		return
 	}
 
+	if read {
+		rt.publishToConversation(ctx, conversationId, events.StatusUpdated, dto.StatusUpdatedEvent{UserId: ctx.UserID, Status: "read"})
+	}
+
 	messages := helpers.ConvertToSentMessages(database_chat)
 	participants := helpers.ConvertUsers(database_conversation.Participants)
 	name := database_conversation.Name
@@ -263,8 +311,20 @@ func (rt *_router) getConversation(w http.ResponseWriter, r *http.Request, ps ht
 		Participants:   participants,
 		IsGroup:        isGroup,
 		Photo:          photo,
+		MessageTtl:     database_conversation.MessageTtl,
+		Pins:           helpers.ConvertPins(pins),
 		Messages:       messages,
 	}
+	if isGroup {
+		permissions := helpers.ConvertGroupPermissions(database_conversation.Permissions)
+		conversation.Permissions = &permissions
+		conversation.HistoryVisibility = database_conversation.HistoryVisibility
+	}
+
+	if hasMore {
+		conversation.HasMoreMessages = true
+		conversation.MessagesCursor = &messages[0].MessageId
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(conversation)
@@ -273,3 +333,58 @@ func (rt *_router) getConversation(w http.ResponseWriter, r *http.Request, ps ht
 		return
 	}
 }
+
+func (rt *_router) setMessageTtl(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SetMessageTtlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Zero is accepted as a synonym of null: both turn disappearing messages off
+	ttl := req.MessageTtl
+	if ttl != nil && *ttl == 0 {
+		ttl = nil
+	}
+	if ttl != nil {
+		minTtl := int64(constraints.MinMessageTtl / time.Second)
+		maxTtl := int64(constraints.MaxMessageTtl / time.Second)
+		if *ttl < minTtl || *ttl > maxTtl {
+			http.Error(w, fmt.Sprintf("Message TTL must be between %d and %d seconds", minTtl, maxTtl), http.StatusBadRequest)
+			return
+		}
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	if err := rt.db.UpdateMessageTtl(conversationId, ttl); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to update message TTL")
+		return
+	}
+
+	resp, ok := rt.announceChange(ctx, conversationId, ctx.UserID, database.KindTtlChanged, database.SystemData{MessageTtl: ttl})
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
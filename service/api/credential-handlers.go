@@ -0,0 +1,154 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/julienschmidt/httprouter"
+)
+
+// checkLoginCredential verifies the password sent with a login request. It reports whether the login creates the
+// account and whether it may proceed; when it may not, the error response has already been written.
+func (rt *_router) checkLoginCredential(w http.ResponseWriter, ctx reqcontext.RequestContext, req dto.LoginRequest) (bool, bool) {
+	if req.Password != nil && !isValidPasswordLength(*req.Password) {
+		http.Error(w, passwordLengthMessage(), http.StatusBadRequest)
+		return false, false
+	}
+
+	credential, err := rt.db.GetCredentialByUsername(req.Username)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve credential")
+		return false, false
+	}
+
+	if credential == nil {
+		_, err := rt.db.GetUserId(req.Username)
+		exists := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve user")
+			return false, false
+		}
+
+		// An existing account only gets a password through /me/password, so that whoever knows its username
+		// cannot claim it by logging in with a password of their choice
+		if exists && rt.requireCredentials {
+			http.Error(w, "This account has no password yet, set one from a device where you are logged in", http.StatusUnauthorized)
+			return false, false
+		}
+		if !exists && req.Password == nil && rt.requireCredentials {
+			http.Error(w, "A password is required to log in", http.StatusUnauthorized)
+			return false, false
+		}
+		return !exists, true
+	}
+
+	if req.Password == nil {
+		http.Error(w, "This account is protected by a password", http.StatusUnauthorized)
+		return false, false
+	}
+
+	if !rt.verifyPassword(w, ctx, credential, *req.Password) {
+		return false, false
+	}
+	return false, true
+}
+
+// verifyPassword checks password against a stored credential, enforcing the lockout after too many failures. On
+// failure the error response has already been written.
+func (rt *_router) verifyPassword(w http.ResponseWriter, ctx reqcontext.RequestContext, credential *database.Credential, password string) bool {
+	if credential.IsLocked {
+		http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+		return false
+	}
+
+	match, err := helpers.VerifyPassword(password, credential.PasswordHash)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to verify password")
+		return false
+	}
+
+	if !match {
+		if err := rt.db.RecordFailedLogin(credential.UserId, constraints.MaxFailedLogins, constraints.LoginLockoutDuration); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to record failed login")
+			return false
+		}
+		http.Error(w, "Wrong username or password", http.StatusUnauthorized)
+		return false
+	}
+
+	if credential.FailedAttempts > 0 {
+		if err := rt.db.ResetFailedLogins(credential.UserId); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to reset failed logins")
+			return false
+		}
+	}
+	return true
+}
+
+func (rt *_router) setPassword(userId int64, password string) error {
+	hash, err := helpers.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return rt.db.SetPassword(userId, hash)
+}
+
+func (rt *_router) setMyPassword(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var req dto.SetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidPasswordLength(req.NewPassword) {
+		http.Error(w, passwordLengthMessage(), http.StatusBadRequest)
+		return
+	}
+
+	credential, err := rt.db.GetCredential(ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve credential")
+		return
+	}
+
+	// Changing an existing password requires the current one
+	if credential != nil {
+		if req.CurrentPassword == nil {
+			http.Error(w, "The current password is required", http.StatusBadRequest)
+			return
+		}
+		if !rt.verifyPassword(w, ctx, credential, *req.CurrentPassword) {
+			return
+		}
+	}
+
+	if err := rt.setPassword(ctx.UserID, req.NewPassword); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to set password")
+		return
+	}
+
+	// Log out every other device: they authenticated with the old password
+	if err := rt.db.RemoveOtherSessions(ctx.UserID, ctx.SessionID); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to revoke other sessions")
+		return
+	}
+	rt.bus.EndSessions(ctx.UserID, func(id int64) bool { return id != ctx.SessionID })
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isValidPasswordLength(password string) bool {
+	return len(password) >= constraints.MinPasswordLength && len(password) <= constraints.MaxPasswordLength
+}
+
+func passwordLengthMessage() string {
+	return fmt.Sprintf("Password must be between %d and %d characters", constraints.MinPasswordLength, constraints.MaxPasswordLength)
+}
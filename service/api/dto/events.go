@@ -0,0 +1,69 @@
+package dto
+
+// Payloads of the events sent on the event stream. message.created and message.edited carry a SentMessage.
+
+type MessageDeletedEvent struct {
+	MessageId   int64 `json:"messageId"`
+	ForEveryone bool  `json:"forEveryone"` // false when the recipient deleted the message only for themselves
+}
+
+type ReactionChangedEvent struct {
+	MessageId int64      `json:"messageId"`
+	Reactions []Reaction `json:"reactions"`
+}
+
+type ThreadUpdatedEvent struct {
+	MessageId        int64   `json:"messageId"` // the root of the thread
+	ThreadReplyCount int64   `json:"threadReplyCount"`
+	LastReplyAt      *string `json:"lastThreadReplyAt,omitempty"`
+}
+
+type PinChangedEvent struct {
+	MessageId int64 `json:"messageId"`
+	Pinned    bool  `json:"pinned"`
+}
+
+type PollChangedEvent struct {
+	MessageId int64 `json:"messageId"`
+	Poll      Poll  `json:"poll"`
+}
+
+type StarChangedEvent struct {
+	MessageId int64 `json:"messageId"`
+	Starred   bool  `json:"starred"`
+}
+
+type ParticipantAddedEvent struct {
+	Participants []User `json:"participants"` // the users that joined the conversation
+}
+
+type ParticipantRemovedEvent struct {
+	UserId    int64  `json:"userId"`
+	RemovedBy *int64 `json:"removedBy,omitempty"` // the admin who removed the user, absent when they left
+	Banned    bool   `json:"banned,omitempty"`    // whether the user was banned from the group too
+}
+
+// join_request.created carries a JoinRequest.
+
+type JoinRequestResolvedEvent struct {
+	UserId   int64 `json:"userId"`   // the user who asked to join
+	Approved bool  `json:"approved"` // false when the request was denied
+}
+
+type RoleChangedEvent struct {
+	UserId int64  `json:"userId"`
+	Role   string `json:"role"` // the new role of the participant
+}
+
+type PermissionsChangedEvent struct {
+	Permissions GroupPermissions `json:"permissions"`
+}
+
+type GroupRenamedEvent struct {
+	Name string `json:"name"`
+}
+
+type StatusUpdatedEvent struct {
+	UserId int64  `json:"userId"` // the recipient whose status changed
+	Status string `json:"status"` // "delivered" or "read"
+}
@@ -1,7 +1,14 @@
 package dto
 
 type LoginRequest struct {
-	Username string `json:"username"`
+	Username    string  `json:"username"`
+	Password    *string `json:"password,omitempty"`
+	DeviceLabel *string `json:"deviceLabel,omitempty"`
+}
+
+type SetPasswordRequest struct {
+	CurrentPassword *string `json:"currentPassword,omitempty"`
+	NewPassword     string  `json:"newPassword"`
 }
 
 type SetUsernameRequest struct {
@@ -32,14 +39,64 @@ type SetGroupNameRequest struct {
 	Name string `json:"name"`
 }
 
+// SetGroupPermissionsRequest changes who may perform the actions whose setting is present, to "members" or "admins".
+type SetGroupPermissionsRequest struct {
+	Rename      *string `json:"rename"`
+	ChangePhoto *string `json:"changePhoto"`
+	AddMembers  *string `json:"addMembers"`
+	Pin         *string `json:"pin"`
+}
+
+// SetHistoryVisibilityRequest chooses which messages the participants of a group see: "full" for all of them,
+// "joined" for the ones sent after they joined.
+type SetHistoryVisibilityRequest struct {
+	HistoryVisibility string `json:"historyVisibility"`
+}
+
+type CreateInviteRequest struct {
+	ExpiresAt        *string `json:"expiresAt,omitempty"` // RFC 3339 time after which the link no longer works
+	MaxUses          *int64  `json:"maxUses,omitempty"`   // number of times the link can be used, unlimited if absent
+	RequiresApproval bool    `json:"requiresApproval,omitempty"`
+}
+
+type SetMessageTtlRequest struct {
+	MessageTtl *int64 `json:"messageTtl"` // seconds, null or 0 turns disappearing messages off
+}
+
 type SetGroupPhotoRequest struct {
 	Photo *Photo `json:"photo,omitempty"`
 }
 
 type SendMessageRequest struct {
-	ReplyToMessageId *int64  `json:"replyTo"`
-	Text             *string `json:"text,omitempty"`
-	Photo            *Photo  `json:"photo,omitempty"`
+	ReplyToMessageId *int64   `json:"replyTo"`
+	Text             *string  `json:"text,omitempty"`
+	Photo            *Photo   `json:"photo,omitempty"`
+	FileId           *string  `json:"fileId,omitempty"`                 // attach a file uploaded with POST /files
+	Poll             *NewPoll `json:"poll,omitempty"`                   // send a poll instead of a text or photo
+	VoiceNoteId      *string  `json:"voiceNoteId,omitempty"`            // send a voice note uploaded with POST /voice_notes instead
+	SendAt           *string  `json:"sendAt,omitempty"`                 // RFC 3339 time to send the message at, instead of right away
+	ThreadRootId     *int64   `json:"threadRootId,omitempty"`           // post the message as a reply in this thread
+	InConversation   bool     `json:"alsoSendToConversation,omitempty"` // also show the thread reply in the conversation
+}
+
+type NewPoll struct {
+	Question       string   `json:"question"`
+	Options        []string `json:"options"`
+	MultipleChoice bool     `json:"multipleChoice,omitempty"`
+	Anonymous      bool     `json:"anonymous,omitempty"`
+	ClosesAt       *string  `json:"closesAt,omitempty"` // RFC 3339 time after which votes are no longer accepted
+}
+
+type VotePollRequest struct {
+	Options []int `json:"options"` // positions of the chosen options, empty to withdraw the vote
+}
+
+type RescheduleMessageRequest struct {
+	SendAt string `json:"sendAt"`
+}
+
+type EditMessageRequest struct {
+	Text string `json:"text"`
 }
 
 type ForwardMessageRequest struct {
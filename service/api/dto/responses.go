@@ -4,6 +4,33 @@ type User struct {
 	Username string `json:"username,omitempty"`
 	UserId   int64  `json:"userId,omitempty"`
 	Photo    *Photo `json:"photo,omitempty"`
+	Role     string `json:"role,omitempty"`     // "owner", "admin" or "member", only in the participants of a conversation
+	JoinedAt string `json:"joinedAt,omitempty"` // when they joined, only in the participants of a conversation
+}
+
+// GroupPermissions holds who may perform each of the restricted actions in a group: "members" or "admins", which
+// includes the owner.
+type GroupPermissions struct {
+	Rename      string `json:"rename"`
+	ChangePhoto string `json:"changePhoto"`
+	AddMembers  string `json:"addMembers"`
+	Pin         string `json:"pin"`
+}
+
+type LoginResponse struct {
+	User
+	Token string `json:"token"`
+}
+
+type Session struct {
+	SessionId   int64   `json:"sessionId"`
+	DeviceLabel *string `json:"deviceLabel,omitempty"`
+	UserAgent   string  `json:"userAgent"`
+	IpAddress   string  `json:"ipAddress"`
+	CreatedAt   string  `json:"createdAt"`
+	LastSeenAt  string  `json:"lastSeenAt"`
+	ExpiresAt   string  `json:"expiresAt"`
+	Current     bool    `json:"current"` // true for the session used to make the request
 }
 
 type Photo struct {
@@ -11,6 +38,21 @@ type Photo struct {
 	Path    string `json:"path"`
 }
 
+// File is an attachment other than a photo, downloaded from GET /files/{fileId}.
+type File struct {
+	FileId   string `json:"fileId"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"` // in bytes
+	MimeType string `json:"mimeType"`
+}
+
+// VoiceNote is a voice note uploaded to be sent in a message.
+type VoiceNote struct {
+	File
+	DurationMs int64 `json:"durationMs"`
+	Waveform   []int `json:"waveform"` // from 0 for the quietest to 255 for the loudest part of the recording
+}
+
 type ConversationPreview struct {
 	ConversationId int64        `json:"conversationId,omitempty"`
 	Name           string       `json:"name,omitempty"`
@@ -18,15 +60,70 @@ type ConversationPreview struct {
 	IsGroup        bool         `json:"isGroup"`
 	Photo          *Photo       `json:"photo,omitempty"`
 	LastMessage    *SentMessage `json:"lastMessage,omitempty"` // optional, can be nil if no messages exist
+	MessageTtl     *int64       `json:"messageTtl,omitempty"`  // seconds new messages last, absent if they do not disappear
+	UnreadCount    int64        `json:"unreadCount"`           // messages the user has not read yet, system messages excluded
 }
 
 type Chat struct {
-	ConversationId int64         `json:"conversationId"`
-	Name           string        `json:"name,omitempty"`
-	Participants   []User        `json:"participants"`
-	IsGroup        bool          `json:"isGroup"`
-	Photo          *Photo        `json:"photo,omitempty"`
-	Messages       []SentMessage `json:"messages,omitempty"` // messages in the chat, can be empty if no messages exist
+	ConversationId    int64             `json:"conversationId"`
+	Name              string            `json:"name,omitempty"`
+	Participants      []User            `json:"participants"`
+	IsGroup           bool              `json:"isGroup"`
+	Photo             *Photo            `json:"photo,omitempty"`
+	MessageTtl        *int64            `json:"messageTtl,omitempty"`        // seconds new messages last, absent if they do not disappear
+	Permissions       *GroupPermissions `json:"permissions,omitempty"`       // who may change the group, absent for private conversations
+	HistoryVisibility string            `json:"historyVisibility,omitempty"` // "full" or "joined", absent for private conversations
+	Pins              []PinnedMessage   `json:"pins"`                        // pinned messages, the most recently pinned first
+	Messages          []SentMessage     `json:"messages,omitempty"`          // latest page of messages, can be empty if no messages exist
+	HasMoreMessages   bool              `json:"hasMoreMessages"`             // older messages can be loaded with MessagesCursor
+	MessagesCursor    *int64            `json:"messagesCursor,omitempty"`    // pass as `before` to load the previous page
+}
+
+type MessagePage struct {
+	Messages   []SentMessage `json:"messages"`             // in chronological order
+	HasMore    bool          `json:"hasMore"`              // more messages exist in the requested direction
+	NextCursor *int64        `json:"nextCursor,omitempty"` // pass with the same parameter to load the next page
+}
+
+type SearchResult struct {
+	Message SentMessage `json:"message"`
+	Snippet string      `json:"snippet"` // HTML-escaped excerpt of the text, with the matches wrapped in <mark>
+}
+
+type SearchResults struct {
+	Results    []SearchResult `json:"results"`              // best match first
+	HasMore    bool           `json:"hasMore"`              // more results exist past this page
+	NextCursor *string        `json:"nextCursor,omitempty"` // pass as `cursor` to load the next page
+}
+
+type ThreadPage struct {
+	Root       SentMessage   `json:"root"`                 // the message that started the thread
+	Messages   []SentMessage `json:"messages"`             // replies in chronological order
+	HasMore    bool          `json:"hasMore"`              // more replies exist in the requested direction
+	NextCursor *int64        `json:"nextCursor,omitempty"` // pass with the same parameter to load the next page
+}
+
+type MessageContext struct {
+	Messages        []SentMessage `json:"messages"`        // in chronological order, including the target message
+	TargetMessageId int64         `json:"targetMessageId"` // the message the context was requested for
+	HasMoreBefore   bool          `json:"hasMoreBefore"`   // older messages can be loaded with `before` set to the first message
+	HasMoreAfter    bool          `json:"hasMoreAfter"`    // newer messages can be loaded with `after` set to the last message
+}
+
+type Change struct {
+	Kind           string `json:"kind"` // e.g., "message.created", "participant.removed"
+	ConversationId int64  `json:"conversationId"`
+	MessageId      *int64 `json:"messageId,omitempty"`
+	UserId         *int64 `json:"userId,omitempty"`
+	Timestamp      string `json:"timestamp"`
+}
+
+type SyncResponse struct {
+	Cursor        string                `json:"cursor"`        // pass as `since` in the next request
+	HasMore       bool                  `json:"hasMore"`       // more changes are available right away
+	Changes       []Change              `json:"changes"`       // in the order they happened
+	Messages      []SentMessage         `json:"messages"`      // current state of the messages referenced by changes
+	Conversations []ConversationPreview `json:"conversations"` // current state of the conversations whose metadata or members changed
 }
 
 type Reaction struct {
@@ -36,14 +133,115 @@ type Reaction struct {
 }
 
 type SentMessage struct {
-	MessageId        int64      `json:"messageId"`
-	Text             *string    `json:"text"`
-	ConversationId   int64      `json:"conversationId"` // ID of the conversation this message belongs to
-	SentBy           User       `json:"sentBy"`
-	Timestamp        string     `json:"timestamp"`
-	Photo            *Photo     `json:"photo,omitempty"`
-	Reactions        []Reaction `json:"reactions,omitempty"` // aggregated reactions from rows sharing the same messageId
-	ReplyToMessageId *int64     `json:"replyTo,omitempty"`
-	Status           string     `json:"status"`      // e.g., "sent", "delivered", "read"
-	IsForwarded      bool       `json:"isForwarded"` // indicates if the message is forwarded
+	MessageId        int64       `json:"messageId"`
+	Text             *string     `json:"text"`
+	ConversationId   int64       `json:"conversationId"` // ID of the conversation this message belongs to
+	SentBy           User        `json:"sentBy"`
+	Timestamp        string      `json:"timestamp"`
+	EditedAt         *string     `json:"editedAt,omitempty"`  // time of the last edit, absent if never edited
+	Deleted          bool        `json:"deleted,omitempty"`   // deleted for everyone: only the sender and timestamp are left
+	ExpiresAt        *string     `json:"expiresAt,omitempty"` // when the message disappears, absent if it does not
+	Kind             string      `json:"kind"`                // "message", or the kind of system message
+	System           *SystemData `json:"system,omitempty"`    // details of a system message
+	Poll             *Poll       `json:"poll,omitempty"`      // options and results of a poll, whose question is the text
+	Photo            *Photo      `json:"photo,omitempty"`
+	File             *File       `json:"file,omitempty"`
+	DurationMs       *int64      `json:"durationMs,omitempty"` // length of a voice message, whose recording is the file
+	Waveform         []int       `json:"waveform,omitempty"`   // loudness of a voice message over time, from 0 to 255
+	Reactions        []Reaction  `json:"reactions,omitempty"`  // aggregated reactions from rows sharing the same messageId
+	Mentions         []Mention   `json:"mentions,omitempty"`   // users mentioned in the text, in order of appearance
+	ReplyToMessageId *int64      `json:"replyTo,omitempty"`
+	ThreadRootId     *int64      `json:"threadRootId,omitempty"`           // the thread the message was posted in
+	InConversation   bool        `json:"alsoSentToConversation,omitempty"` // a thread reply also shown in the conversation
+	ThreadReplyCount int64       `json:"threadReplyCount,omitempty"`       // replies in the thread the message started
+	LastReplyAt      *string     `json:"lastThreadReplyAt,omitempty"`      // time of the latest reply in that thread
+	Pinned           bool        `json:"pinned,omitempty"`                 // pinned in its conversation
+	Status           string      `json:"status,omitempty"`                 // e.g., "sent", "delivered", "read"; absent for system messages
+	IsForwarded      bool        `json:"isForwarded"`                      // indicates if the message is forwarded
+}
+
+type Poll struct {
+	MultipleChoice bool         `json:"multipleChoice"`
+	Anonymous      bool         `json:"anonymous"`
+	ClosesAt       *string      `json:"closesAt,omitempty"`
+	Closed         bool         `json:"closed"`
+	Options        []PollOption `json:"options"`
+	VoterCount     int          `json:"voterCount"`        // users who voted for at least one option
+	MyVotes        []int        `json:"myVotes,omitempty"` // options chosen by the caller, only in vote responses
+}
+
+type PollOption struct {
+	Text   string `json:"text"`
+	Votes  int    `json:"votes"`
+	Voters []User `json:"voters,omitempty"` // absent for anonymous polls
+}
+
+// Mention is a reference to a user in the text of a message, spanning Length characters (Unicode code points) from
+// Position. Clients render it with the current username of User, which may differ from the text after a rename.
+type Mention struct {
+	User     User `json:"user"`
+	Position int  `json:"position"`
+	Length   int  `json:"length"`
+}
+
+type ScheduledMessage struct {
+	ScheduledMessageId int64   `json:"scheduledMessageId"`
+	ConversationId     int64   `json:"conversationId"`
+	Text               *string `json:"text"`
+	Photo              *Photo  `json:"photo,omitempty"`
+	File               *File   `json:"file,omitempty"`
+	ReplyToMessageId   *int64  `json:"replyTo,omitempty"`
+	ThreadRootId       *int64  `json:"threadRootId,omitempty"`
+	InConversation     bool    `json:"alsoSendToConversation,omitempty"`
+	SendAt             string  `json:"sendAt"`
+	CreatedAt          string  `json:"createdAt"`
+}
+
+type SystemData struct {
+	MessageTtl        *int64  `json:"messageTtl,omitempty"`        // ttl.changed: the new setting, absent when turned off
+	MessageId         *int64  `json:"messageId,omitempty"`         // message.pinned: the pinned message
+	User              *User   `json:"user,omitempty"`              // member.added and member.removed: the added or removed member
+	Banned            bool    `json:"banned,omitempty"`            // member.removed: whether they were banned from the group too
+	Name              *string `json:"name,omitempty"`              // group.renamed: the new name
+	HistoryVisibility *string `json:"historyVisibility,omitempty"` // history.changed: the new setting
+}
+
+type Invite struct {
+	Code             string  `json:"code"`
+	ConversationId   int64   `json:"conversationId"`
+	CreatedBy        User    `json:"createdBy"`
+	CreatedAt        string  `json:"createdAt"`
+	ExpiresAt        *string `json:"expiresAt,omitempty"`
+	MaxUses          *int64  `json:"maxUses,omitempty"`
+	Uses             int64   `json:"uses"`
+	RequiresApproval bool    `json:"requiresApproval"`
+}
+
+type JoinRequest struct {
+	User        User   `json:"user"`
+	InviteCode  string `json:"inviteCode"`
+	RequestedAt string `json:"requestedAt"`
+}
+
+// JoinResult tells a user who used an invite link whether they joined the group or asked to join it.
+type JoinResult struct {
+	ConversationId int64  `json:"conversationId"`
+	Status         string `json:"status"` // "joined", or "pending" until an admin approves or denies the request
+}
+
+type Ban struct {
+	User     User   `json:"user"`
+	BannedBy User   `json:"bannedBy"`
+	BannedAt string `json:"bannedAt"`
+}
+
+type PinnedMessage struct {
+	Message  SentMessage `json:"message"`
+	PinnedBy User        `json:"pinnedBy"`
+	PinnedAt string      `json:"pinnedAt"`
+}
+
+type MessageRevision struct {
+	Text      *string `json:"text"`
+	Timestamp string  `json:"timestamp"` // when this version was written
 }
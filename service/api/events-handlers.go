@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/events"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// wsWriteTimeout is the time allowed to write a single frame to the client
+	wsWriteTimeout = 10 * time.Second
+
+	// wsPongTimeout is the time allowed to read the next pong from the client
+	wsPongTimeout = 60 * time.Second
+
+	// wsPingInterval must be shorter than wsPongTimeout
+	wsPingInterval = 50 * time.Second
+
+	// sseHeartbeatInterval keeps idle Server-Sent Events streams open through proxies
+	sseHeartbeatInterval = 30 * time.Second
+)
+
+// checkOrigin reports whether a WebSocket handshake comes from a page allowed to open the event stream. Browsers do
+// not apply the CORS policy to WebSockets, so the origin is checked here. Requests without an origin do not come from
+// a browser, and the web UI served by this server has its same origin.
+func (rt *_router) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range rt.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rt *_router) getEvents(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     rt.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already replied to the client
+		ctx.Logger.WithError(err).Error("Failed to upgrade to WebSocket")
+		return
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			ctx.Logger.WithError(err).Debug("Failed to close WebSocket")
+		}
+	}()
+
+	sub := rt.bus.Subscribe(ctx.UserID, ctx.SessionID)
+	defer sub.Close()
+
+	// The client never sends anything meaningful, but reading is needed to process pongs and close frames
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if !ok {
+				// Server shutting down, session revoked, or the client was too slow
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-closed:
+			return
+		}
+	}
+}
+
+// getEventStream delivers the same events as getEvents as a Server-Sent Events stream, for clients behind proxies that
+// do not allow WebSockets. A client reconnecting with the Last-Event-ID header first receives the events it missed.
+func (rt *_router) getEventStream(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var lastEventId int64
+	lastEventIdHeader := r.Header.Get("Last-Event-ID")
+	if lastEventIdHeader == "" {
+		// Fallback for EventSource polyfills that cannot set headers
+		lastEventIdHeader = r.URL.Query().Get("lastEventId")
+	}
+	if lastEventIdHeader != "" {
+		id, err := strconv.ParseInt(lastEventIdHeader, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventId = id
+	}
+
+	// The stream stays open much longer than the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to clear the write deadline")
+		return
+	}
+
+	// Subscribe before replaying, so that nothing published in between is lost
+	sub := rt.bus.Subscribe(ctx.UserID, ctx.SessionID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Replay what the client missed. Live events up to replayedUpTo have already been sent.
+	replayedUpTo := lastEventId
+	if lastEventIdHeader != "" {
+		for {
+			missed, err := rt.db.GetUserEventsSince(ctx.UserID, replayedUpTo, constraints.EventReplayBatchSize)
+			if err != nil {
+				ctx.Logger.WithError(err).Error("Failed to retrieve missed events")
+				return
+			}
+			for _, userEvent := range missed {
+				if err := writeServerSentEvent(w, convertUserEvent(userEvent)); err != nil {
+					return
+				}
+				replayedUpTo = userEvent.Seq
+			}
+			if len(missed) < constraints.EventReplayBatchSize {
+				break
+			}
+		}
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				// Server shutting down, session revoked, or the client was too slow: it will reconnect with
+				// Last-Event-ID
+				return
+			}
+			if ev.Id <= replayedUpTo {
+				continue
+			}
+			if err := writeServerSentEvent(w, ev); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeServerSentEvent writes ev in the text/event-stream format, using its sequence number as the event ID.
+func writeServerSentEvent(w http.ResponseWriter, ev events.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.Id, data)
+	return err
+}
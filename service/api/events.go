@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
+)
+
+// publishToConversation sends an event to every current participant of a conversation. Failures are only logged: the
+// request that caused the event has already succeeded.
+func (rt *_router) publishToConversation(ctx reqcontext.RequestContext, conversationId int64, eventType events.Type, payload interface{}) {
+	participantIds, err := rt.db.GetParticipantIds(conversationId)
+	if err != nil {
+		ctx.Logger.WithError(err).Error("Failed to retrieve participant IDs for event")
+		return
+	}
+	rt.publish(ctx, participantIds, conversationId, eventType, payload)
+}
+
+// publish stores an event in the event log of each given user, so that it can be replayed, and sends it to their open
+// event streams.
+func (rt *_router) publish(ctx reqcontext.RequestContext, userIds []int64, conversationId int64, eventType events.Type, payload interface{}) {
+	var encodedPayload *string
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			ctx.Logger.WithError(err).Error("Failed to encode event payload")
+			return
+		}
+		s := string(b)
+		encodedPayload = &s
+	}
+
+	rt.publishMu.Lock()
+	defer rt.publishMu.Unlock()
+
+	for _, userId := range userIds {
+		seq, timestamp, err := rt.db.InsertUserEvent(userId, string(eventType), conversationId, encodedPayload)
+		if err != nil {
+			ctx.Logger.WithError(err).Error("Failed to store event")
+			continue
+		}
+
+		rt.bus.Publish(userId, events.Event{
+			Id:             seq,
+			Type:           eventType,
+			ConversationId: conversationId,
+			Timestamp:      timestamp,
+			Payload:        payload,
+		})
+	}
+}
+
+// convertUserEvent rebuilds a stored event so it can be replayed.
+func convertUserEvent(ev database.UserEvent) events.Event {
+	var payload interface{}
+	if ev.Payload != nil {
+		payload = json.RawMessage(*ev.Payload)
+	}
+	return events.Event{
+		Id:             ev.Seq,
+		Type:           events.Type(ev.Type),
+		ConversationId: ev.ConversationId,
+		Timestamp:      ev.CreatedAt,
+		Payload:        payload,
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// attachmentsDir holds the uploaded files. Unlike the images in ./uploads it is not served statically, as downloads
+// are restricted to the conversations the files were sent to.
+const attachmentsDir = "./attachments"
+
+// receiveUpload reads the "file" field of a multipart upload, which must not exceed the maximum upload size. On
+// failure it writes the error response and returns ok false, otherwise the caller must call done once finished with
+// the file.
+func (rt *_router) receiveUpload(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext) (file multipart.File, handler *multipart.FileHeader, done func(), ok bool) {
+	// Leave some room for the multipart headers around the file
+	maxBodySize := rt.maxUploadSize + 1<<20
+	if r.ContentLength > maxBodySize {
+		http.Error(w, fmt.Sprintf("File is too large. Maximum allowed size is %s.", helpers.FormatFileSize(rt.maxUploadSize)), http.StatusRequestEntityTooLarge)
+		return nil, nil, nil, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, "Invalid or too large multipart form", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+	removeForm := func() {
+		if err := r.MultipartForm.RemoveAll(); err != nil {
+			ctx.Logger.WithError(err).Error("Failed to remove temporary multipart files")
+		}
+	}
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		removeForm()
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+	done = func() {
+		if err := file.Close(); err != nil {
+			ctx.Logger.WithError(err).Error("Failed to close file")
+		}
+		removeForm()
+	}
+
+	if handler.Size > rt.maxUploadSize {
+		done()
+		http.Error(w, fmt.Sprintf("File is too large. Maximum allowed size is %s.", helpers.FormatFileSize(rt.maxUploadSize)), http.StatusRequestEntityTooLarge)
+		return nil, nil, nil, false
+	}
+	if handler.Size == 0 {
+		done()
+		http.Error(w, "File is empty", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+	return file, handler, done, true
+}
+
+// storeAttachment saves content in the attachments directory under a new ID, and records it with the details of
+// dbFile. It returns the stored file.
+func (rt *_router) storeAttachment(ctx reqcontext.RequestContext, content io.Reader, dbFile database.File) (*database.File, error) {
+	if err := os.MkdirAll(attachmentsDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating attachments directory: %w", err)
+	}
+
+	// The stored name does not depend on the uploaded one, which is only kept in the database
+	dbFile.FileId = uuid.New().String()
+	dbFile.Path = filepath.Join(attachmentsDir, dbFile.FileId)
+	dst, err := os.Create(dbFile.Path)
+	if err != nil {
+		return nil, fmt.Errorf("creating destination file: %w", err)
+	}
+	dbFile.Size, err = io.Copy(dst, content)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = rt.db.InsertFile(dbFile)
+	}
+	if err != nil {
+		if err := os.Remove(dbFile.Path); err != nil {
+			ctx.Logger.WithError(err).Error("Failed to remove partially stored file")
+		}
+		return nil, fmt.Errorf("storing file: %w", err)
+	}
+	return &dbFile, nil
+}
+
+// uploadFile stores a file that the caller can then attach to messages. Its type is detected from the content and
+// must be one of the allowed file types.
+func (rt *_router) uploadFile(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	file, handler, done, ok := rt.receiveUpload(w, r, ctx)
+	if !ok {
+		return
+	}
+	defer done()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to read file for MIME type validation")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to reset file pointer")
+		return
+	}
+
+	filename := helpers.SanitizeFilename(handler.Filename)
+	mimeType := helpers.DetectFileType(head[:n], filename)
+	if !helpers.IsAllowedType(mimeType, rt.allowedFileTypes) {
+		http.Error(w, "File type "+mimeType+" is not allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	dbFile, err := rt.storeAttachment(ctx, file, database.File{
+		Filename:   filename,
+		MimeType:   mimeType,
+		UploadedBy: ctx.UserID,
+	})
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to save uploaded file")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(helpers.ConvertFile(dbFile))
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// downloadFile sends a file to its uploader or to the participants of a conversation it was sent to, as an
+// attachment with its original name.
+func (rt *_router) downloadFile(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	fileId := ps.ByName("fileId")
+
+	allowed, err := rt.db.CanAccessFile(fileId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check file access")
+		return
+	}
+	if !allowed {
+		// Files the caller cannot access are indistinguishable from missing ones
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	dbFile, err := rt.db.GetFile(fileId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve file")
+		return
+	}
+	if dbFile == nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(dbFile.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to open file")
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ctx.Logger.WithError(err).Error("Failed to close file")
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to read file information")
+		return
+	}
+
+	w.Header().Set("Content-Type", dbFile.MimeType)
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": dbFile.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
@@ -5,27 +5,80 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Reewd/WASAproject/service/api/constraints"
 	"github.com/Reewd/WASAproject/service/api/dto"
 	"github.com/Reewd/WASAproject/service/api/helpers"
 	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
 	"github.com/julienschmidt/httprouter"
 )
 
+// Pick the setting of one of the restricted actions from the permissions of a group.
+func permissionRename(p database.GroupPermissions) string      { return p.Rename }
+func permissionChangePhoto(p database.GroupPermissions) string { return p.ChangePhoto }
+func permissionAddMembers(p database.GroupPermissions) string  { return p.AddMembers }
+func permissionPin(p database.GroupPermissions) string         { return p.Pin }
+
+// checkGroupPermission checks that the caller takes part in a conversation and that their role allows the action
+// described by action, whose setting is picked from the permissions of the conversation by setting. On failure it
+// writes the error response and returns false.
+func (rt *_router) checkGroupPermission(w http.ResponseWriter, ctx reqcontext.RequestContext, conversationId int64, setting func(database.GroupPermissions) string, action string) bool {
+	role, err := rt.db.GetParticipantRole(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant role")
+		return false
+	}
+	if role == "" {
+		http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+		return false
+	}
+
+	permissions, err := rt.db.GetGroupPermissions(conversationId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve group permissions")
+		return false
+	}
+	if !database.Allows(setting(permissions), role) {
+		http.Error(w, "Only admins can "+action, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkAdmin checks that the caller is the owner or an admin of a conversation, which in private conversations nobody
+// is, so that they can perform the action described by action. It returns the role of the caller. On failure it writes
+// the error response and returns false.
+func (rt *_router) checkAdmin(w http.ResponseWriter, ctx reqcontext.RequestContext, conversationId int64, action string) (string, bool) {
+	role, err := rt.db.GetParticipantRole(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant role")
+		return "", false
+	}
+	if role == "" {
+		http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+		return "", false
+	}
+	if !database.IsAdmin(role) {
+		http.Error(w, "Only admins can "+action, http.StatusForbidden)
+		return "", false
+	}
+	return role, true
+}
+
 func (rt *_router) addToGroup(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 	var req dto.AddToGroupRequest
 
 	conversationIdPath := ps.ByName("conversationId")
 	if conversationIdPath == "" {
-		ctx.Logger.Error("Debug: conversationIdPath is empty")
 		http.Error(w, "Conversation ID is required", http.StatusBadRequest)
 		return
 	}
 
 	conversationId, err := strconv.ParseInt(conversationIdPath, 10, 64) // Ensure conversationId is a valid integer
 	if err != nil {
-		ctx.Logger.WithError(err).Error("Debug: Failed to parse conversationIdPath")
 		http.Error(w, "The ID should be an integer", http.StatusBadRequest)
 		return
 	}
@@ -36,16 +89,7 @@ func (rt *_router) addToGroup(w http.ResponseWriter, r *http.Request, ps httprou
 		return
 	}
 
-	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
-	if err != nil {
-		ctx.Logger.WithError(err).Error("Failed to check participant existence")
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
-		return
-	}
-
-	if !exists {
-		ctx.Logger.Error("User is not a participant of the conversation")
-		http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+	if !rt.checkGroupPermission(w, ctx, conversationId, permissionAddMembers, "add members to this group") {
 		return
 	}
 
@@ -62,22 +106,37 @@ func (rt *_router) addToGroup(w http.ResponseWriter, r *http.Request, ps httprou
 		return
 	}
 
-	err = rt.db.InsertParticipants(conversationId, participantsIds)
+	var banned []string
+	for i, id := range participantsIds {
+		isBanned, err := rt.db.IsBanned(conversationId, id)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to check bans")
+			return
+		}
+		if isBanned {
+			banned = append(banned, req.Participants[i])
+		}
+	}
+	if len(banned) > 0 {
+		http.Error(w, "These users are banned from this group: "+strings.Join(banned, ", "), http.StatusForbidden)
+		return
+	}
+
+	addedIds, err := rt.db.InsertParticipants(conversationId, participantsIds)
 	if err != nil {
 		ctx.Logger.WithError(err).Error("Failed to add participants to group")
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to add participants to group")
 		return
 	}
 
-	participants, err := rt.db.GetParticipants(conversationId)
+	// The participants are added already: if they cannot be listed, the request still succeeds
+	resp, err := rt.announceParticipants(ctx, conversationId, ctx.UserID, addedIds)
 	if err != nil {
-		ctx.Logger.WithError(err).Error("Failed to retrieve participants")
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve participants")
+		ctx.Logger.WithError(err).Error("Failed to announce new participants")
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	resp := helpers.ConvertUsers(participants)
-
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		ctx.Logger.WithError(err).Error("Failed to encode JSON response")
@@ -86,6 +145,54 @@ func (rt *_router) addToGroup(w http.ResponseWriter, r *http.Request, ps httprou
 	}
 }
 
+// announceParticipants records in the chat that actorId added the users of userIds to a conversation, or joined it if
+// they are the only one, and tells the participants, the new ones included. It returns all the participants.
+func (rt *_router) announceParticipants(ctx reqcontext.RequestContext, conversationId int64, actorId int64, userIds []int64) ([]dto.User, error) {
+	for _, id := range userIds {
+		userId := id
+		kind, data := database.KindAdded, database.SystemData{UserId: &userId}
+		if userId == actorId {
+			kind, data = database.KindJoined, database.SystemData{}
+		}
+		rt.announceChange(ctx, conversationId, actorId, kind, data)
+	}
+
+	participants, err := rt.db.GetParticipants(conversationId)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving participants: %w", err)
+	}
+
+	all := helpers.ConvertUsers(participants)
+	added := make([]dto.User, 0, len(userIds))
+	for _, participant := range all {
+		for _, id := range userIds {
+			if participant.UserId == id {
+				added = append(added, participant)
+				break
+			}
+		}
+	}
+	if len(added) > 0 {
+		rt.publishToConversation(ctx, conversationId, events.ParticipantAdded, dto.ParticipantAddedEvent{Participants: added})
+	}
+	return all, nil
+}
+
+// adminIds returns the owner and the admins of a conversation.
+func (rt *_router) adminIds(conversationId int64) ([]int64, error) {
+	participants, err := rt.db.GetParticipants(conversationId)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, participant := range participants {
+		if database.IsAdmin(participant.Role) {
+			ids = append(ids, participant.UserId)
+		}
+	}
+	return ids, nil
+}
+
 func (rt *_router) leaveGroup(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 	var req dto.LeaveGroupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -110,9 +217,158 @@ func (rt *_router) leaveGroup(w http.ResponseWriter, r *http.Request, ps httprou
 		return
 	}
 
+	rt.announceChange(ctx, req.ConversationId, ctx.UserID, database.KindLeft, database.SystemData{})
+
+	// Notify the remaining participants, and the other devices of the user who left
+	remainingIds, err := rt.db.GetParticipantIds(req.ConversationId)
+	if err != nil {
+		ctx.Logger.WithError(err).Error("Failed to retrieve participant IDs for event")
+	} else {
+		rt.publish(ctx, append(remainingIds, ctx.UserID), req.ConversationId, events.ParticipantRemoved, dto.ParticipantRemovedEvent{UserId: ctx.UserID})
+	}
+
+	// A group is never left without an owner
+	newOwnerId, err := rt.db.EnsureOwner(req.ConversationId)
+	if err != nil {
+		ctx.Logger.WithError(err).Error("Failed to hand over group ownership")
+	} else if newOwnerId != nil {
+		rt.publishToConversation(ctx, req.ConversationId, events.RoleChanged, dto.RoleChangedEvent{UserId: *newOwnerId, Role: database.RoleOwner})
+	}
+
 	w.WriteHeader(http.StatusNoContent) // No content response for successful leave
 }
 
+// removeFromGroup removes the participant addressed by the request path from a group, and with ?ban=true also keeps
+// them from being added back until the ban is lifted. Admins can remove members, and only the owner can remove admins.
+func (rt *_router) removeFromGroup(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	userId, err := strconv.ParseInt(ps.ByName("userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ban := false
+	if value := r.URL.Query().Get("ban"); value != "" {
+		ban, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "ban must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	callerRole, ok := rt.checkAdmin(w, ctx, conversationId, "remove members from this group")
+	if !ok {
+		return
+	}
+	if userId == ctx.UserID {
+		http.Error(w, "You cannot remove yourself, leave the group instead", http.StatusBadRequest)
+		return
+	}
+
+	targetRole, err := rt.db.GetParticipantRole(conversationId, userId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant role")
+		return
+	}
+	if targetRole == "" {
+		http.Error(w, "User is not a participant of this conversation", http.StatusNotFound)
+		return
+	}
+	if targetRole == database.RoleOwner {
+		http.Error(w, "The owner cannot be removed from the group", http.StatusForbidden)
+		return
+	}
+	if targetRole == database.RoleAdmin && callerRole != database.RoleOwner {
+		http.Error(w, "Only the owner can remove admins", http.StatusForbidden)
+		return
+	}
+
+	if err := rt.db.RemoveParticipant(conversationId, userId); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to remove participant")
+		return
+	}
+	if ban {
+		if err := rt.db.InsertBan(conversationId, userId, ctx.UserID); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to ban participant")
+			return
+		}
+	}
+
+	rt.announceChange(ctx, conversationId, ctx.UserID, database.KindRemoved, database.SystemData{UserId: &userId, Banned: ban})
+
+	// Notify the remaining participants, and the user who was removed
+	remainingIds, err := rt.db.GetParticipantIds(conversationId)
+	if err != nil {
+		ctx.Logger.WithError(err).Error("Failed to retrieve participant IDs for event")
+	} else {
+		rt.publish(ctx, append(remainingIds, userId), conversationId, events.ParticipantRemoved, dto.ParticipantRemovedEvent{UserId: userId, RemovedBy: &ctx.UserID, Banned: ban})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getGroupBans lists the users banned from a group to its owner and admins.
+func (rt *_router) getGroupBans(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "see the bans of this group"); !ok {
+		return
+	}
+
+	bans, err := rt.db.GetBans(conversationId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve bans")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(helpers.ConvertBans(bans)); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// liftBan lets the user addressed by the request path be added back to a group. Only its owner and admins can.
+func (rt *_router) liftBan(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	userId, err := strconv.ParseInt(ps.ByName("userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "lift bans in this group"); !ok {
+		return
+	}
+
+	found, err := rt.db.RemoveBan(conversationId, userId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to lift ban")
+		return
+	}
+	if !found {
+		http.Error(w, "User is not banned from this group", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (rt *_router) setGroupName(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 	var req dto.SetGroupNameRequest
 
@@ -135,14 +391,7 @@ func (rt *_router) setGroupName(w http.ResponseWriter, r *http.Request, ps httpr
 		return
 	}
 
-	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
-	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
-		return
-	}
-
-	if !exists {
-		http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+	if !rt.checkGroupPermission(w, ctx, conversationId, permissionRename, "rename this group") {
 		return
 	}
 
@@ -156,6 +405,10 @@ func (rt *_router) setGroupName(w http.ResponseWriter, r *http.Request, ps httpr
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to update group name")
 		return
 	}
+
+	rt.publishToConversation(ctx, conversationId, events.GroupRenamed, dto.GroupRenamedEvent{Name: req.Name})
+	rt.announceChange(ctx, conversationId, ctx.UserID, database.KindRenamed, database.SystemData{Name: &req.Name})
+
 	resp := map[string]string{"name": req.Name}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -187,13 +440,7 @@ func (rt *_router) setGroupPhoto(w http.ResponseWriter, r *http.Request, ps http
 		return
 	}
 
-	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
-	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
-		return
-	}
-	if !exists {
-		http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+	if !rt.checkGroupPermission(w, ctx, conversationId, permissionChangePhoto, "change the photo of this group") {
 		return
 	}
 
@@ -211,3 +458,174 @@ func (rt *_router) setGroupPhoto(w http.ResponseWriter, r *http.Request, ps http
 		return
 	}
 }
+
+// promoteAdmin makes a member of a group one of its admins. Only the owner can.
+func (rt *_router) promoteAdmin(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	rt.setAdminRole(w, ps, ctx, database.RoleAdmin)
+}
+
+// demoteAdmin makes an admin of a group a member again. Only the owner can.
+func (rt *_router) demoteAdmin(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	rt.setAdminRole(w, ps, ctx, database.RoleMember)
+}
+
+// setAdminRole gives the participant addressed by the request path the role of admin or member, and tells the
+// participants. Giving a participant the role they already have has no effect.
+func (rt *_router) setAdminRole(w http.ResponseWriter, ps httprouter.Params, ctx reqcontext.RequestContext, role string) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	userId, err := strconv.ParseInt(ps.ByName("userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	callerRole, err := rt.db.GetParticipantRole(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant role")
+		return
+	}
+	if callerRole == "" {
+		http.Error(w, "You are not a participant of this conversation", http.StatusForbidden)
+		return
+	}
+	// Private conversations have no owner, so this also rejects them
+	if callerRole != database.RoleOwner {
+		http.Error(w, "Only the owner can promote or demote admins", http.StatusForbidden)
+		return
+	}
+
+	currentRole, err := rt.db.GetParticipantRole(conversationId, userId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant role")
+		return
+	}
+	if currentRole == "" {
+		http.Error(w, "User is not a participant of this conversation", http.StatusNotFound)
+		return
+	}
+	if currentRole == database.RoleOwner {
+		http.Error(w, "The role of the owner cannot be changed", http.StatusBadRequest)
+		return
+	}
+	if currentRole == role {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	found, err := rt.db.SetParticipantRole(conversationId, userId, role)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to change participant role")
+		return
+	}
+	if !found {
+		http.Error(w, "User is not a participant of this conversation", http.StatusNotFound)
+		return
+	}
+
+	rt.publishToConversation(ctx, conversationId, events.RoleChanged, dto.RoleChangedEvent{UserId: userId, Role: role})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setGroupPermissions changes who may perform the restricted actions of a group, which its owner and admins can do.
+// Settings missing from the request are left as they are.
+func (rt *_router) setGroupPermissions(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SetGroupPermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for _, setting := range []*string{req.Rename, req.ChangePhoto, req.AddMembers, req.Pin} {
+		if setting != nil && *setting != database.AllowMembers && *setting != database.AllowAdmins {
+			http.Error(w, fmt.Sprintf("Permissions must be %q or %q", database.AllowMembers, database.AllowAdmins), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "change the permissions of this group"); !ok {
+		return
+	}
+
+	permissions, err := rt.db.GetGroupPermissions(conversationId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve group permissions")
+		return
+	}
+	if req.Rename != nil {
+		permissions.Rename = *req.Rename
+	}
+	if req.ChangePhoto != nil {
+		permissions.ChangePhoto = *req.ChangePhoto
+	}
+	if req.AddMembers != nil {
+		permissions.AddMembers = *req.AddMembers
+	}
+	if req.Pin != nil {
+		permissions.Pin = *req.Pin
+	}
+
+	if err := rt.db.UpdateGroupPermissions(conversationId, permissions); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to update group permissions")
+		return
+	}
+
+	resp := helpers.ConvertGroupPermissions(permissions)
+	rt.publishToConversation(ctx, conversationId, events.PermissionsChanged, dto.PermissionsChangedEvent{Permissions: resp})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// setHistoryVisibility chooses whether the participants of a group see all of its messages or only the ones sent after
+// they joined, which its owner and admins can do.
+func (rt *_router) setHistoryVisibility(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SetHistoryVisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.HistoryVisibility != database.HistoryFull && req.HistoryVisibility != database.HistorySinceJoining {
+		http.Error(w, fmt.Sprintf("History visibility must be %q or %q", database.HistoryFull, database.HistorySinceJoining), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "change the history visibility of this group"); !ok {
+		return
+	}
+
+	if err := rt.db.UpdateHistoryVisibility(conversationId, req.HistoryVisibility); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to update history visibility")
+		return
+	}
+
+	resp, ok := rt.announceChange(ctx, conversationId, ctx.UserID, database.KindHistoryChanged, database.SystemData{HistoryVisibility: &req.HistoryVisibility})
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
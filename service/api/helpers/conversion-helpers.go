@@ -1,6 +1,9 @@
 package helpers
 
 import (
+	"html"
+	"strings"
+
 	"github.com/Reewd/WASAproject/service/api/dto"
 	"github.com/Reewd/WASAproject/service/database"
 )
@@ -10,6 +13,17 @@ func ConvertUser(user database.User) dto.User {
 		UserId:   user.UserId,
 		Username: user.Username,
 		Photo:    ConvertPhoto(user.Photo),
+		Role:     user.Role,
+		JoinedAt: user.JoinedAt,
+	}
+}
+
+func ConvertGroupPermissions(permissions database.GroupPermissions) dto.GroupPermissions {
+	return dto.GroupPermissions{
+		Rename:      permissions.Rename,
+		ChangePhoto: permissions.ChangePhoto,
+		AddMembers:  permissions.AddMembers,
+		Pin:         permissions.Pin,
 	}
 }
 
@@ -21,6 +35,23 @@ func ConvertUsers(users []database.User) []dto.User {
 	return dtoUsers
 }
 
+func ConvertSessions(sessions []database.Session, currentSessionId int64) []dto.Session {
+	dtoSessions := make([]dto.Session, 0, len(sessions))
+	for _, session := range sessions {
+		dtoSessions = append(dtoSessions, dto.Session{
+			SessionId:   session.SessionId,
+			DeviceLabel: session.DeviceLabel,
+			UserAgent:   session.UserAgent,
+			IpAddress:   session.IpAddress,
+			CreatedAt:   session.CreatedAt,
+			LastSeenAt:  session.LastUsedAt,
+			ExpiresAt:   session.ExpiresAt,
+			Current:     session.SessionId == currentSessionId,
+		})
+	}
+	return dtoSessions
+}
+
 func ConvertReactions(reactions []database.ReactionView) []dto.Reaction {
 	convertedReactions := make([]dto.Reaction, 0, len(reactions))
 	for _, reaction := range reactions {
@@ -33,21 +64,25 @@ func ConvertReactions(reactions []database.ReactionView) []dto.Reaction {
 	return convertedReactions
 }
 
+func ConvertMentions(mentions []database.Mention) []dto.Mention {
+	if len(mentions) == 0 {
+		return nil
+	}
+	dtoMentions := make([]dto.Mention, 0, len(mentions))
+	for _, mention := range mentions {
+		dtoMentions = append(dtoMentions, dto.Mention{
+			User:     ConvertUser(mention.User),
+			Position: mention.Position,
+			Length:   mention.Length,
+		})
+	}
+	return dtoMentions
+}
+
 func ConvertToSentMessages(messages []database.MessageView) []dto.SentMessage {
 	sentMessages := make([]dto.SentMessage, 0, len(messages))
 	for _, msg := range messages {
-		sentMessages = append(sentMessages, dto.SentMessage{
-			MessageId:        msg.MessageId,
-			Text:             msg.Text,
-			SentBy:           ConvertUser(msg.SentBy),
-			Timestamp:        msg.Timestamp,
-			Photo:            ConvertPhoto(msg.Photo),
-			Reactions:        ConvertReactions(msg.Reactions),
-			ReplyToMessageId: msg.ReplyTo,
-			Status:           msg.Status,
-			ConversationId:   msg.ConversationId,
-			IsForwarded:      msg.IsForwarded,
-		})
+		sentMessages = append(sentMessages, ConvertToSentMessage(msg))
 	}
 	return sentMessages
 }
@@ -58,13 +93,199 @@ func ConvertToSentMessage(msg database.MessageView) dto.SentMessage {
 		Text:             msg.Text,
 		SentBy:           ConvertUser(msg.SentBy),
 		Timestamp:        msg.Timestamp,
+		EditedAt:         msg.EditedAt,
+		Deleted:          msg.Deleted,
+		ExpiresAt:        msg.ExpiresAt,
+		Kind:             msg.Kind,
+		System:           ConvertSystemData(msg.System),
+		Poll:             ConvertPoll(msg.Poll),
 		Photo:            ConvertPhoto(msg.Photo),
+		File:             ConvertFile(msg.File),
+		DurationMs:       voiceDuration(msg.File),
+		Waveform:         voiceWaveform(msg.File),
 		Reactions:        ConvertReactions(msg.Reactions),
+		Mentions:         ConvertMentions(msg.Mentions),
 		ReplyToMessageId: msg.ReplyTo,
+		ThreadRootId:     msg.ThreadRootId,
+		InConversation:   msg.ThreadRootId != nil && msg.InConversation,
+		ThreadReplyCount: msg.ThreadReplies,
+		LastReplyAt:      msg.LastReplyAt,
+		Pinned:           msg.Pinned,
 		Status:           msg.Status,
+		ConversationId:   msg.ConversationId,
+		IsForwarded:      msg.IsForwarded,
+	}
+}
+
+func ConvertPins(pins []database.Pin) []dto.PinnedMessage {
+	dtoPins := make([]dto.PinnedMessage, 0, len(pins))
+	for _, pin := range pins {
+		dtoPins = append(dtoPins, dto.PinnedMessage{
+			Message:  ConvertToSentMessage(pin.Message),
+			PinnedBy: ConvertUser(pin.PinnedBy),
+			PinnedAt: pin.PinnedAt,
+		})
+	}
+	return dtoPins
+}
+
+func ConvertInvite(invite database.Invite) dto.Invite {
+	return dto.Invite{
+		Code:             invite.Code,
+		ConversationId:   invite.ConversationId,
+		CreatedBy:        ConvertUser(invite.CreatedBy),
+		CreatedAt:        invite.CreatedAt,
+		ExpiresAt:        invite.ExpiresAt,
+		MaxUses:          invite.MaxUses,
+		Uses:             invite.Uses,
+		RequiresApproval: invite.RequiresApproval,
+	}
+}
+
+func ConvertInvites(invites []database.Invite) []dto.Invite {
+	dtoInvites := make([]dto.Invite, 0, len(invites))
+	for _, invite := range invites {
+		dtoInvites = append(dtoInvites, ConvertInvite(invite))
+	}
+	return dtoInvites
+}
+
+func ConvertJoinRequest(request database.JoinRequest) dto.JoinRequest {
+	return dto.JoinRequest{
+		User:        ConvertUser(request.User),
+		InviteCode:  request.InviteCode,
+		RequestedAt: request.RequestedAt,
+	}
+}
+
+func ConvertJoinRequests(requests []database.JoinRequest) []dto.JoinRequest {
+	dtoRequests := make([]dto.JoinRequest, 0, len(requests))
+	for _, request := range requests {
+		dtoRequests = append(dtoRequests, ConvertJoinRequest(request))
+	}
+	return dtoRequests
+}
+
+func ConvertBans(bans []database.Ban) []dto.Ban {
+	dtoBans := make([]dto.Ban, 0, len(bans))
+	for _, ban := range bans {
+		dtoBans = append(dtoBans, dto.Ban{
+			User:     ConvertUser(ban.User),
+			BannedBy: ConvertUser(ban.BannedBy),
+			BannedAt: ban.BannedAt,
+		})
+	}
+	return dtoBans
+}
+
+func ConvertScheduledMessage(sm database.ScheduledMessage) dto.ScheduledMessage {
+	return dto.ScheduledMessage{
+		ScheduledMessageId: sm.ScheduledId,
+		ConversationId:     sm.ConversationId,
+		Text:               sm.Text,
+		Photo:              ConvertPhoto(sm.Photo),
+		File:               ConvertFile(sm.File),
+		ReplyToMessageId:   sm.ReplyTo,
+		ThreadRootId:       sm.ThreadRootId,
+		InConversation:     sm.ThreadRootId != nil && sm.InConversation,
+		SendAt:             sm.SendAt,
+		CreatedAt:          sm.CreatedAt,
+	}
+}
+
+func ConvertScheduledMessages(scheduled []database.ScheduledMessage) []dto.ScheduledMessage {
+	dtoScheduled := make([]dto.ScheduledMessage, 0, len(scheduled))
+	for _, sm := range scheduled {
+		dtoScheduled = append(dtoScheduled, ConvertScheduledMessage(sm))
+	}
+	return dtoScheduled
+}
+
+func ConvertRevisions(revisions []database.MessageRevision) []dto.MessageRevision {
+	dtoRevisions := make([]dto.MessageRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		dtoRevisions = append(dtoRevisions, dto.MessageRevision{
+			Text:      revision.Text,
+			Timestamp: revision.Timestamp,
+		})
+	}
+	return dtoRevisions
+}
+
+// snippetHighlighter marks up the matches of an HTML-escaped search snippet. The delimiters are control characters,
+// which escaping leaves untouched.
+var snippetHighlighter = strings.NewReplacer(database.HighlightStart, "<mark>", database.HighlightEnd, "</mark>")
+
+func ConvertSearchHits(hits []database.SearchHit) []dto.SearchResult {
+	results := make([]dto.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, dto.SearchResult{
+			Message: ConvertToSentMessage(hit.Message),
+			Snippet: snippetHighlighter.Replace(html.EscapeString(hit.Snippet)),
+		})
+	}
+	return results
+}
+
+func ConvertSystemData(data *database.SystemData) *dto.SystemData {
+	if data == nil {
+		return nil
+	}
+	var user *dto.User
+	if data.User != nil {
+		converted := ConvertUser(*data.User)
+		user = &converted
+	}
+	return &dto.SystemData{
+		MessageTtl:        data.MessageTtl,
+		MessageId:         data.MessageId,
+		User:              user,
+		Banned:            data.Banned,
+		Name:              data.Name,
+		HistoryVisibility: data.HistoryVisibility,
+	}
+}
+
+func ConvertPoll(poll *database.Poll) *dto.Poll {
+	if poll == nil {
+		return nil
+	}
+	options := make([]dto.PollOption, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		var voters []dto.User
+		if option.Voters != nil {
+			voters = ConvertUsers(option.Voters)
+		}
+		options = append(options, dto.PollOption{
+			Text:   option.Text,
+			Votes:  option.Votes,
+			Voters: voters,
+		})
+	}
+	return &dto.Poll{
+		MultipleChoice: poll.MultipleChoice,
+		Anonymous:      poll.Anonymous,
+		ClosesAt:       poll.ClosesAt,
+		Closed:         poll.Closed,
+		Options:        options,
+		VoterCount:     poll.VoterCount,
 	}
 }
 
+func ConvertChanges(changes []database.Change) []dto.Change {
+	dtoChanges := make([]dto.Change, 0, len(changes))
+	for _, change := range changes {
+		dtoChanges = append(dtoChanges, dto.Change{
+			Kind:           change.Kind,
+			ConversationId: change.ConversationId,
+			MessageId:      change.MessageId,
+			UserId:         change.UserId,
+			Timestamp:      change.Timestamp,
+		})
+	}
+	return dtoChanges
+}
+
 func ConvertPhoto(photo *database.Photo) *dto.Photo {
 	if photo == nil {
 		return nil
@@ -74,3 +295,43 @@ func ConvertPhoto(photo *database.Photo) *dto.Photo {
 		Path:    photo.Path,
 	}
 }
+
+// ConvertVoiceNote converts a file uploaded as a voice note.
+func ConvertVoiceNote(file *database.File) dto.VoiceNote {
+	return dto.VoiceNote{
+		File:       *ConvertFile(file),
+		DurationMs: *file.DurationMs,
+		Waveform:   voiceWaveform(file),
+	}
+}
+
+func voiceDuration(file *database.File) *int64 {
+	if file == nil {
+		return nil
+	}
+	return file.DurationMs
+}
+
+// voiceWaveform converts the waveform of a voice note to numbers, which would otherwise be encoded as base64.
+func voiceWaveform(file *database.File) []int {
+	if file == nil || !file.IsVoiceNote() {
+		return nil
+	}
+	waveform := make([]int, len(file.Waveform))
+	for i, value := range file.Waveform {
+		waveform[i] = int(value)
+	}
+	return waveform
+}
+
+func ConvertFile(file *database.File) *dto.File {
+	if file == nil {
+		return nil
+	}
+	return &dto.File{
+		FileId:   file.FileId,
+		Filename: file.Filename,
+		Size:     file.Size,
+		MimeType: file.MimeType,
+	}
+}
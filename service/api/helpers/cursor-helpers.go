@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const syncCursorPrefix = "c1:"
+const searchCursorPrefix = "s1:"
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// EncodeSyncCursor turns a change log position into the opaque cursor returned to clients.
+func EncodeSyncCursor(changeId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncCursorPrefix + strconv.FormatInt(changeId, 10)))
+}
+
+// DecodeSyncCursor returns the change log position encoded in a cursor made by EncodeSyncCursor.
+func DecodeSyncCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), syncCursorPrefix) {
+		return 0, errInvalidCursor
+	}
+
+	changeId, err := strconv.ParseInt(strings.TrimPrefix(string(raw), syncCursorPrefix), 10, 64)
+	if err != nil || changeId < 0 {
+		return 0, errInvalidCursor
+	}
+	return changeId, nil
+}
+
+// EncodeSearchCursor turns the number of search results already returned into the opaque cursor of the next page.
+func EncodeSearchCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(searchCursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeSearchCursor returns the offset encoded in a cursor made by EncodeSearchCursor.
+func DecodeSearchCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), searchCursorPrefix) {
+		return 0, errInvalidCursor
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), searchCursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+// ParseOptionalID parses an optional ID query parameter, returning nil when it is absent.
+func ParseOptionalID(query url.Values, name string) (*int64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &id, nil
+}
+
+// ParsePageLimit parses the limit query parameter of a paginated endpoint, returning defaultLimit when it is absent.
+func ParsePageLimit(query url.Values, defaultLimit int, maxLimit int) (int, error) {
+	value := query.Get("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return limit, nil
+}
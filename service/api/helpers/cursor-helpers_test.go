@@ -0,0 +1,154 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"math"
+	"net/url"
+	"testing"
+)
+
+func TestSyncCursorRoundTrip(t *testing.T) {
+	for _, changeId := range []int64{0, 1, 42, math.MaxInt64} {
+		cursor := EncodeSyncCursor(changeId)
+		got, err := DecodeSyncCursor(cursor)
+		if err != nil {
+			t.Errorf("DecodeSyncCursor(%q): %v", cursor, err)
+			continue
+		}
+		if got != changeId {
+			t.Errorf("DecodeSyncCursor(EncodeSyncCursor(%d)) = %d", changeId, got)
+		}
+	}
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	for _, offset := range []int{0, 1, 50, math.MaxInt32} {
+		cursor := EncodeSearchCursor(offset)
+		got, err := DecodeSearchCursor(cursor)
+		if err != nil {
+			t.Errorf("DecodeSearchCursor(%q): %v", cursor, err)
+			continue
+		}
+		if got != offset {
+			t.Errorf("DecodeSearchCursor(EncodeSearchCursor(%d)) = %d", offset, got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"not base64", "c1:12"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("c1:1"))},
+		{"no prefix", encode("12")},
+		{"unknown prefix", encode("c2:12")},
+		{"no position", encode("c1:")},
+		{"not a number", encode("c1:abc")},
+		{"negative", encode("c1:-1")},
+		{"overflow", encode("c1:9223372036854775808")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeSyncCursor(tt.cursor); err == nil {
+				t.Errorf("DecodeSyncCursor(%q) accepted an invalid cursor", tt.cursor)
+			}
+		})
+	}
+
+	// The cursors of the two endpoints cannot be swapped
+	if _, err := DecodeSyncCursor(EncodeSearchCursor(3)); err == nil {
+		t.Error("DecodeSyncCursor accepted a search cursor")
+	}
+	if _, err := DecodeSearchCursor(EncodeSyncCursor(3)); err == nil {
+		t.Error("DecodeSearchCursor accepted a sync cursor")
+	}
+	if _, err := DecodeSearchCursor(encode("s1:-5")); err == nil {
+		t.Error("DecodeSearchCursor accepted a negative offset")
+	}
+}
+
+func TestParseOptionalID(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int64
+		wantNil bool
+		wantErr bool
+	}{
+		{query: "", wantNil: true},
+		{query: "before=", wantNil: true},
+		{query: "after=7", wantNil: true},
+		{query: "before=0", want: 0},
+		{query: "before=123", want: 123},
+		{query: "before=-1", wantErr: true},
+		{query: "before=abc", wantErr: true},
+		{query: "before=1.5", wantErr: true},
+		{query: "before=9223372036854775808", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParseOptionalID(query, "before")
+			switch {
+			case tt.wantErr:
+				if err == nil {
+					t.Errorf("ParseOptionalID = %v, want an error", *got)
+				}
+			case err != nil:
+				t.Errorf("ParseOptionalID: %v", err)
+			case tt.wantNil:
+				if got != nil {
+					t.Errorf("ParseOptionalID = %d, want nil", *got)
+				}
+			case got == nil || *got != tt.want:
+				t.Errorf("ParseOptionalID = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePageLimit(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: 20},
+		{query: "limit=", want: 20},
+		{query: "limit=1", want: 1},
+		{query: "limit=100", want: 100},
+		{query: "limit=0", wantErr: true},
+		{query: "limit=101", wantErr: true},
+		{query: "limit=-3", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParsePageLimit(query, 20, 100)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParsePageLimit = %d, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParsePageLimit = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+)
+
+// oleSignature starts the legacy Office formats (.doc, .xls, .ppt), which are OLE compound files.
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// zipContainerTypes are the formats stored as zip archives, which content sniffing cannot tell from a plain zip.
+var zipContainerTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+}
+
+var oleTypes = map[string]string{
+	".doc": "application/msword",
+	".xls": "application/vnd.ms-excel",
+	".ppt": "application/vnd.ms-powerpoint",
+}
+
+// DetectFileType determines the MIME type of an upload from its first bytes. The filename extension only refines the
+// type of containers that the content alone does not identify, so that it cannot pass a file off as another type.
+func DetectFileType(head []byte, filename string) string {
+	mimeType := http.DetectContentType(head)
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch {
+	case mimeType == "application/zip" && zipContainerTypes[ext] != "":
+		return zipContainerTypes[ext]
+	case bytes.HasPrefix(head, oleSignature) && oleTypes[ext] != "":
+		return oleTypes[ext]
+	case mimeType == "text/plain" && ext == ".csv":
+		return "text/csv"
+	}
+	return mimeType
+}
+
+// IsAllowedType reports whether mimeType is one of the allowed types.
+func IsAllowedType(mimeType string, allowed []string) bool {
+	for _, a := range allowed {
+		if mimeType == a {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeFilename keeps the last element of an uploaded file's name, without control characters and at most
+// constraints.MaxFilenameLength bytes long, so that it can be sent back in a Content-Disposition header.
+func SanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+
+	for len(name) > constraints.MaxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// FormatFileSize formats a number of bytes for error messages, e.g. "10 MB".
+func FormatFileSize(size int64) string {
+	switch {
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", size>>20)
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%d KB", size>>10)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+)
+
+func TestDetectFileType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	zip := []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00")
+	ole := append(append([]byte{}, oleSignature...), make([]byte, 16)...)
+
+	tests := []struct {
+		name     string
+		head     []byte
+		filename string
+		want     string
+	}{
+		{"pdf", []byte("%PDF-1.7\n"), "report.pdf", "application/pdf"},
+		{"png", png, "photo.png", "image/png"},
+		{"plain text", []byte("just some notes\n"), "notes.txt", "text/plain"},
+		{"empty file", nil, "empty.txt", "text/plain"},
+		{"csv", []byte("name,age\nmaria,30\n"), "people.csv", "text/csv"},
+		{"csv extension on html", []byte("<html><body>hi</body></html>"), "page.csv", "text/html"},
+		{"docx", zip, "letter.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"upper case extension", zip, "SHEET.XLSX", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"odt", zip, "letter.odt", "application/vnd.oasis.opendocument.text"},
+		{"plain zip", zip, "archive.zip", "application/zip"},
+		{"zip with another extension", zip, "archive.txt", "application/zip"},
+		{"legacy word", ole, "letter.doc", "application/msword"},
+		{"legacy excel", ole, "sheet.xls", "application/vnd.ms-excel"},
+		{"ole with another extension", ole, "setup.msi", "application/octet-stream"},
+		{"png named as docx", png, "letter.docx", "image/png"},
+		{"png named as doc", png, "letter.doc", "image/png"},
+		{"binary", []byte{0x00, 0x01, 0x02, 0x03}, "data.bin", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFileType(tt.head, tt.filename); got != tt.want {
+				t.Errorf("DetectFileType(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"spaces inside", "my report.pdf", "my report.pdf"},
+		{"unicode", "relazione è pronta.pdf", "relazione è pronta.pdf"},
+		{"unix path", "/home/maria/report.pdf", "report.pdf"},
+		{"windows path", `C:\Users\maria\report.pdf`, "report.pdf"},
+		{"traversal", "../../etc/passwd", "passwd"},
+		{"trailing slash", "folder/", "folder"},
+		{"control characters", "re\x00po\nrt\t.pdf", "report.pdf"},
+		{"invalid utf-8", "re\xffport.pdf", "report.pdf"},
+		{"surrounding spaces", "  report.pdf  ", "report.pdf"},
+		{"empty", "", "file"},
+		{"only spaces", "   ", "file"},
+		{"dot", ".", "file"},
+		{"dot dot", "..", "file"},
+		{"root", "/", "file"},
+		{"only control characters", "\x01\x02", "file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameLength(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"ascii", strings.Repeat("a", constraints.MaxFilenameLength+45) + ".pdf"},
+		{"two-byte characters", strings.Repeat("è", constraints.MaxFilenameLength)},
+		{"four-byte characters", strings.Repeat("👋", constraints.MaxFilenameLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeFilename(tt.in)
+			if len(got) > constraints.MaxFilenameLength {
+				t.Errorf("SanitizeFilename kept %d bytes, want at most %d", len(got), constraints.MaxFilenameLength)
+			}
+			// Only whole characters are cut, at most one character short of the limit
+			if !utf8.ValidString(got) || !strings.HasPrefix(tt.in, got) {
+				t.Errorf("SanitizeFilename cut a character: %q", got)
+			}
+			if len(got) <= constraints.MaxFilenameLength-utf8.UTFMax {
+				t.Errorf("SanitizeFilename kept %d bytes, cutting more than needed", len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+)
+
+// GenerateInviteCode returns a new random, URL-safe code for an invite link.
+func GenerateInviteCode() (string, error) {
+	buf := make([]byte, constraints.InviteCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// usernameChars matches a whole username; ValidateUsername restricts usernames to it.
+const usernameChars = `[a-zA-Z0-9_]+`
+
+// mentionPattern matches @username tokens that do not follow a word character, so that e-mail addresses are not
+// taken for mentions. The first group is the character before the token, if any.
+var mentionPattern = regexp.MustCompile(`(^|[^a-zA-Z0-9_])@(` + usernameChars + `)`)
+
+// MentionToken is an @username token found in a text, spanning Length characters (Unicode code points) from
+// Position, the @ included.
+type MentionToken struct {
+	Username string
+	Position int
+	Length   int
+}
+
+// ParseMentions returns the @username tokens of a text, in order of appearance.
+func ParseMentions(text string) []MentionToken {
+	var tokens []MentionToken
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		// match[3] is the end of the preceding character, where the @ starts; match[4:6] delimit the username
+		start, end := match[3], match[5]
+		tokens = append(tokens, MentionToken{
+			Username: text[match[4]:match[5]],
+			Position: utf8.RuneCountInString(text[:start]),
+			Length:   utf8.RuneCountInString(text[start:end]),
+		})
+	}
+	return tokens
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []MentionToken
+	}{
+		{"no mention", "hello there", nil},
+		{"lone at sign", "meet @ noon", nil},
+		{"whole text", "@maria", []MentionToken{{"maria", 0, 6}}},
+		{"in a sentence", "hi @maria, how are you?", []MentionToken{{"maria", 3, 6}}},
+		{"several", "@bob and @alice_2", []MentionToken{{"bob", 0, 4}, {"alice_2", 9, 8}}},
+		{"repeated", "@bob @bob", []MentionToken{{"bob", 0, 4}, {"bob", 5, 4}}},
+		{"e-mail address", "write to maria@example.com", nil},
+		{"after punctuation", "(@maria)", []MentionToken{{"maria", 1, 6}}},
+		{"after a newline", "hey\n@maria", []MentionToken{{"maria", 4, 6}}},
+		{"double at sign", "@@maria", []MentionToken{{"maria", 1, 6}}},
+		{"stops at other characters", "@maria.rossi", []MentionToken{{"maria", 0, 6}}},
+		{"positions in code points", "ciao 👋 @maria", []MentionToken{{"maria", 7, 6}}},
+		{"after a non-ASCII letter", "è@maria", []MentionToken{{"maria", 1, 6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseMentions(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseMentionsValidUsernames checks that every username accepted by ValidateUsername is mentioned in full.
+func TestParseMentionsValidUsernames(t *testing.T) {
+	for _, username := range []string{"bob", "Maria_99", "___", "0123456789abcdef"} {
+		if err := ValidateUsername(username); err != nil {
+			t.Fatalf("ValidateUsername(%q): %v", username, err)
+		}
+		got := ParseMentions("hi @" + username + "!")
+		want := []MentionToken{{username, 3, len(username) + 1}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseMentions of @%s = %+v, want %+v", username, got, want)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"bob", true},
+		{"Maria_99", true},
+		{strings.Repeat("a", 16), true},
+		{"", false},
+		{"al", false},
+		{strings.Repeat("a", 17), false},
+		{"maria.rossi", false},
+		{"maria rossi", false},
+		{"maria-rossi", false},
+		{"@maria", false},
+		{"màrìa", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			err := ValidateUsername(tt.username)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateUsername(%q): %v", tt.username, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("ValidateUsername(%q) accepted an invalid username", tt.username)
+			}
+		})
+	}
+}
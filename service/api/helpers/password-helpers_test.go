@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPasswordVerifies(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("hash %q is not an argon2id PHC string", hash)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"same password", "correct horse", true},
+		{"wrong password", "correct horse!", false},
+		{"empty password", "", false},
+		{"different case", "Correct horse", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.password, hash)
+			if err != nil {
+				t.Fatalf("VerifyPassword: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPassword(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPasswordSalts(t *testing.T) {
+	first, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	second, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if first == second {
+		t.Errorf("two hashes of the same password are equal: %q", first)
+	}
+}
+
+// TestVerifyPasswordParameters checks that hashes are verified with the parameters they were made with, so that
+// changing the defaults does not lock out existing accounts.
+func TestVerifyPasswordParameters(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("secret"), salt, 1, 8, 1, 16)
+	hash := fmt.Sprintf("$argon2id$v=%d$m=8,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+	ok, err := VerifyPassword("secret", hash)
+	if err != nil || !ok {
+		t.Errorf("VerifyPassword = %v, %v, want true, nil", ok, err)
+	}
+}
+
+func TestVerifyPasswordInvalidHash(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"empty", ""},
+		{"plain text", "secret"},
+		{"bcrypt", "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{"argon2i", fmt.Sprintf("$argon2i$v=19$m=65536,t=3,p=4$%s$%s", salt, key)},
+		{"missing part", fmt.Sprintf("$argon2id$v=19$m=65536,t=3,p=4$%s", salt)},
+		{"extra part", fmt.Sprintf("$argon2id$v=19$m=65536,t=3,p=4$%s$%s$", salt, key)},
+		{"old version", fmt.Sprintf("$argon2id$v=16$m=65536,t=3,p=4$%s$%s", salt, key)},
+		{"bad version", fmt.Sprintf("$argon2id$version$m=65536,t=3,p=4$%s$%s", salt, key)},
+		{"bad parameters", fmt.Sprintf("$argon2id$v=19$m=lots,t=3,p=4$%s$%s", salt, key)},
+		{"bad salt", fmt.Sprintf("$argon2id$v=19$m=65536,t=3,p=4$%s$%s", "not base64!", key)},
+		{"bad key", fmt.Sprintf("$argon2id$v=19$m=65536,t=3,p=4$%s$%s", salt, "not base64!")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyPassword("secret", tt.hash)
+			if !errors.Is(err, errInvalidPasswordHash) {
+				t.Errorf("VerifyPassword error = %v, want %v", err, errInvalidPasswordHash)
+			}
+			if ok {
+				t.Error("VerifyPassword accepted an invalid hash")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"strings"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+)
+
+// GenerateSessionToken returns a new random, URL-safe session token.
+func GenerateSessionToken() (string, error) {
+	buf := make([]byte, constraints.SessionTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// ExtractBearerToken returns the token carried by an Authorization header value, with or without the "Bearer " prefix.
+func ExtractBearerToken(header string) string {
+	header = strings.TrimSpace(header)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return header
+}
+
+// RemoteIP returns the host part of a request remote address, or the address itself if it has no port.
+func RemoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
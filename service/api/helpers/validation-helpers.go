@@ -2,13 +2,30 @@ package helpers
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 
+	"github.com/Reewd/WASAproject/service/api/constraints"
 	"github.com/Reewd/WASAproject/service/api/dto"
 	"github.com/rivo/uniseg"
 	"github.com/ucarion/emoji"
 )
 
+// usernamePattern is the character set of usernames, the same one @mentions are parsed with.
+var usernamePattern = regexp.MustCompile(`^` + usernameChars + `$`)
+
+// ValidateUsername checks that a username has an allowed length and can be mentioned.
+func ValidateUsername(username string) error {
+	if len(username) < constraints.MinUsernameLength || len(username) > constraints.MaxUsernameLength {
+		return fmt.Errorf("username must be between %d and %d characters", constraints.MinUsernameLength, constraints.MaxUsernameLength)
+	}
+	if !usernamePattern.MatchString(username) {
+		return errors.New("username may only contain letters, digits and underscores")
+	}
+	return nil
+}
+
 func IsSingleEmoji(s string) error {
 	s = strings.TrimSpace(s)
 
@@ -2,12 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 
-	"github.com/Reewd/WASAproject/service/api/constraints"
 	"github.com/Reewd/WASAproject/service/api/dto"
 	"github.com/Reewd/WASAproject/service/api/helpers"
 	"github.com/Reewd/WASAproject/service/api/reqcontext"
@@ -35,9 +35,9 @@ func (rt *_router) uploadImage(w http.ResponseWriter, r *http.Request, ps httpro
 		}
 	}()
 
-	if handler.Size > constraints.MaxFileSize {
+	if handler.Size > rt.maxUploadSize {
 		ctx.Logger.Error("File too large")
-		http.Error(w, "File is too large. Maximum allowed size is 10MB.", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("File is too large. Maximum allowed size is %s.", helpers.FormatFileSize(rt.maxUploadSize)), http.StatusBadRequest)
 		return
 	}
 
@@ -56,15 +56,7 @@ func (rt *_router) uploadImage(w http.ResponseWriter, r *http.Request, ps httpro
 	}
 
 	mimeType := http.DetectContentType(buffer)
-	isValidMimeType := false
-	for _, allowed := range constraints.AllowedMimeTypes {
-		if mimeType == allowed {
-			isValidMimeType = true
-			break
-		}
-	}
-
-	if !isValidMimeType {
+	if !helpers.IsAllowedType(mimeType, rt.allowedImageTypes) {
 		ctx.Logger.Error("Invalid MIME type: " + mimeType)
 		http.Error(w, "Invalid file type.", http.StatusBadRequest)
 		return
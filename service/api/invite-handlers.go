@@ -0,0 +1,340 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
+	"github.com/Reewd/WASAproject/service/globaltime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// parseInvite validates the settings of a new invite link.
+func parseInvite(req dto.CreateInviteRequest) (database.NewInvite, error) {
+	invite := database.NewInvite{RequiresApproval: req.RequiresApproval}
+	if req.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			return database.NewInvite{}, fmt.Errorf("expiresAt must be an RFC 3339 time")
+		}
+		now := globaltime.Now()
+		if !expiresAt.After(now) {
+			return database.NewInvite{}, fmt.Errorf("expiresAt must be in the future")
+		}
+		if expiresAt.Sub(now) > constraints.MaxInviteDuration {
+			return database.NewInvite{}, fmt.Errorf("expiresAt must be within %d days", int(constraints.MaxInviteDuration.Hours()/24))
+		}
+		invite.ExpiresAt = &expiresAt
+	}
+	if req.MaxUses != nil {
+		if *req.MaxUses < 1 || *req.MaxUses > constraints.MaxInviteUses {
+			return database.NewInvite{}, fmt.Errorf("maxUses must be between 1 and %d", constraints.MaxInviteUses)
+		}
+		invite.MaxUses = req.MaxUses
+	}
+	return invite, nil
+}
+
+// createInvite creates an invite link to a group. Only its owner and admins can.
+func (rt *_router) createInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	invite, err := parseInvite(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "create invite links for this group"); !ok {
+		return
+	}
+
+	code, err := helpers.GenerateInviteCode()
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to generate invite code")
+		return
+	}
+	if err := rt.db.InsertInvite(code, conversationId, ctx.UserID, invite); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to create invite link")
+		return
+	}
+
+	created, err := rt.db.GetInvite(code)
+	if err != nil || created == nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve invite link")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(helpers.ConvertInvite(*created)); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// getInvites lists the invite links of a group to its owner and admins.
+func (rt *_router) getInvites(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "see the invite links of this group"); !ok {
+		return
+	}
+
+	invites, err := rt.db.GetInvites(conversationId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve invite links")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(helpers.ConvertInvites(invites)); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// revokeInvite makes an invite link of a group stop working. Only its owner and admins can.
+func (rt *_router) revokeInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "revoke invite links of this group"); !ok {
+		return
+	}
+
+	found, err := rt.db.RemoveInvite(conversationId, ps.ByName("code"))
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to revoke invite link")
+		return
+	}
+	if !found {
+		http.Error(w, "Invite link not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// joinWithInvite adds the caller to the group of an invite link, or, if the link requires approval, asks its admins
+// to add them. Every use counts against the maximum number of uses of the link, whether or not it is approved.
+func (rt *_router) joinWithInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	code := ps.ByName("code")
+	invite, err := rt.db.GetInvite(code)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve invite link")
+		return
+	}
+	if invite == nil {
+		http.Error(w, "Invite link not found", http.StatusNotFound)
+		return
+	}
+	conversationId := invite.ConversationId
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if exists {
+		http.Error(w, "You are already a participant of this conversation", http.StatusConflict)
+		return
+	}
+
+	banned, err := rt.db.IsBanned(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check bans")
+		return
+	}
+	if banned {
+		http.Error(w, "You are banned from this group", http.StatusForbidden)
+		return
+	}
+
+	if invite.RequiresApproval {
+		// Asking again while the request is pending does not use the link again
+		pending, err := rt.db.GetJoinRequest(conversationId, ctx.UserID)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to check join requests")
+			return
+		}
+		if pending != nil {
+			rt.writeJoinResult(w, ctx, http.StatusAccepted, dto.JoinResult{ConversationId: conversationId, Status: "pending"})
+			return
+		}
+	}
+
+	usable, err := rt.db.UseInvite(code)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to use invite link")
+		return
+	}
+	if !usable {
+		http.Error(w, "This invite link has expired or reached its maximum number of uses", http.StatusGone)
+		return
+	}
+
+	if invite.RequiresApproval {
+		if err := rt.db.InsertJoinRequest(conversationId, ctx.UserID, code); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to request to join")
+			return
+		}
+		request, err := rt.db.GetJoinRequest(conversationId, ctx.UserID)
+		if err != nil || request == nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve join request")
+			return
+		}
+
+		adminIds, err := rt.adminIds(conversationId)
+		if err != nil {
+			ctx.Logger.WithError(err).Error("Failed to retrieve admin IDs for event")
+		} else {
+			rt.publish(ctx, adminIds, conversationId, events.JoinRequested, helpers.ConvertJoinRequest(*request))
+		}
+
+		rt.writeJoinResult(w, ctx, http.StatusAccepted, dto.JoinResult{ConversationId: conversationId, Status: "pending"})
+		return
+	}
+
+	addedIds, err := rt.db.InsertParticipants(conversationId, []int64{ctx.UserID})
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to join group")
+		return
+	}
+	if _, err := rt.announceParticipants(ctx, conversationId, ctx.UserID, addedIds); err != nil {
+		ctx.Logger.WithError(err).Error("Failed to announce new participant")
+	}
+
+	rt.writeJoinResult(w, ctx, http.StatusOK, dto.JoinResult{ConversationId: conversationId, Status: "joined"})
+}
+
+func (rt *_router) writeJoinResult(w http.ResponseWriter, ctx reqcontext.RequestContext, status int, result dto.JoinResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// getJoinRequests lists the pending requests to join a group to its owner and admins.
+func (rt *_router) getJoinRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "see the join requests of this group"); !ok {
+		return
+	}
+
+	requests, err := rt.db.GetJoinRequests(conversationId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve join requests")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(helpers.ConvertJoinRequests(requests)); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// approveJoinRequest adds the user addressed by the request path to the group they asked to join.
+func (rt *_router) approveJoinRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	rt.resolveJoinRequest(w, ps, ctx, true)
+}
+
+// denyJoinRequest turns down the request of the user addressed by the request path to join a group.
+func (rt *_router) denyJoinRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	rt.resolveJoinRequest(w, ps, ctx, false)
+}
+
+// resolveJoinRequest approves or denies a pending request to join a group, which its owner and admins can do, and
+// tells the admins and the user who asked.
+func (rt *_router) resolveJoinRequest(w http.ResponseWriter, ps httprouter.Params, ctx reqcontext.RequestContext, approved bool) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	userId, err := strconv.ParseInt(ps.ByName("userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := rt.checkAdmin(w, ctx, conversationId, "approve or deny join requests"); !ok {
+		return
+	}
+
+	if approved {
+		banned, err := rt.db.IsBanned(conversationId, userId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to check bans")
+			return
+		}
+		if banned {
+			http.Error(w, "User is banned from this group", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Removing the request first keeps two admins from resolving it at the same time
+	found, err := rt.db.RemoveJoinRequest(conversationId, userId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to resolve join request")
+		return
+	}
+	if !found {
+		http.Error(w, "No pending join request from this user", http.StatusNotFound)
+		return
+	}
+
+	if approved {
+		addedIds, err := rt.db.InsertParticipants(conversationId, []int64{userId})
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to add participant to group")
+			return
+		}
+		if _, err := rt.announceParticipants(ctx, conversationId, ctx.UserID, addedIds); err != nil {
+			ctx.Logger.WithError(err).Error("Failed to announce new participant")
+		}
+	}
+
+	adminIds, err := rt.adminIds(conversationId)
+	if err != nil {
+		ctx.Logger.WithError(err).Error("Failed to retrieve admin IDs for event")
+	} else {
+		rt.publish(ctx, append(adminIds, userId), conversationId, events.JoinRequestResolved, dto.JoinRequestResolvedEvent{UserId: userId, Approved: approved})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
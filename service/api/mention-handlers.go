@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/julienschmidt/httprouter"
+)
+
+// storeMentions records which participants of a conversation the text of a message mentions, replacing the mentions
+// it had before. Tokens naming users outside of the conversation are left as plain text.
+func (rt *_router) storeMentions(conversationId int64, messageId int64, text *string) error {
+	var tokens []helpers.MentionToken
+	if text != nil {
+		tokens = helpers.ParseMentions(*text)
+	}
+	if len(tokens) == 0 {
+		return rt.db.SetMentions(messageId, nil)
+	}
+
+	users, err := rt.db.GetParticipants(conversationId)
+	if err != nil {
+		return err
+	}
+	participants := make(map[string]database.User, len(users))
+	for _, participant := range users {
+		participants[participant.Username] = participant
+	}
+
+	var mentions []database.Mention
+	for _, token := range tokens {
+		if user, ok := participants[token.Username]; ok {
+			mentions = append(mentions, database.Mention{User: user, Position: token.Position, Length: token.Length})
+		}
+	}
+	return rt.db.SetMentions(messageId, mentions)
+}
+
+// getMyMentions returns, like a page of conversation messages, the messages in which other users mentioned the caller.
+func (rt *_router) getMyMentions(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	query := r.URL.Query()
+	beforeId, err := helpers.ParseOptionalID(query, "before")
+	if err != nil {
+		http.Error(w, "Invalid before cursor", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := helpers.ParsePageLimit(query, constraints.DefaultMessagePageSize, constraints.MaxMessagePageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dbMessages, hasMore, err := rt.db.GetMentionsPage(ctx.UserID, beforeId, limit)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve mentions")
+		return
+	}
+
+	resp := dto.MessagePage{
+		Messages: helpers.ConvertToSentMessages(dbMessages),
+		HasMore:  hasMore,
+	}
+	if hasMore {
+		resp.NextCursor = &resp.Messages[0].MessageId
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Reewd/WASAproject/service/api/constraints"
 	"github.com/Reewd/WASAproject/service/api/dto"
 	"github.com/Reewd/WASAproject/service/api/helpers"
 	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
+	"github.com/Reewd/WASAproject/service/globaltime"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -20,8 +24,26 @@ func (rt *_router) sendMessage(w http.ResponseWriter, r *http.Request, ps httpro
 		return
 	}
 
-	if req.Text == nil && req.Photo == nil {
-		http.Error(w, "You must send either a message or a photo, or both", http.StatusBadRequest)
+	if req.Poll != nil {
+		if req.Text != nil || req.Photo != nil || req.FileId != nil || req.VoiceNoteId != nil {
+			http.Error(w, "A poll cannot be sent together with a message, a photo, a file or a voice note", http.StatusBadRequest)
+			return
+		}
+		if req.SendAt != nil {
+			http.Error(w, "Polls cannot be scheduled", http.StatusBadRequest)
+			return
+		}
+	} else if req.VoiceNoteId != nil {
+		if req.Text != nil || req.Photo != nil || req.FileId != nil {
+			http.Error(w, "A voice note cannot be sent together with a message, a photo or a file", http.StatusBadRequest)
+			return
+		}
+		if req.SendAt != nil {
+			http.Error(w, "Voice notes cannot be scheduled", http.StatusBadRequest)
+			return
+		}
+	} else if req.Text == nil && req.Photo == nil && req.FileId == nil {
+		http.Error(w, "You must send a message, a photo or a file", http.StatusBadRequest)
 		return
 	}
 
@@ -32,7 +54,7 @@ func (rt *_router) sendMessage(w http.ResponseWriter, r *http.Request, ps httpro
 		}
 	}
 
-	photoId, Photo := helpers.ExtractPhoto(req.Photo)
+	photoId, _ := helpers.ExtractPhoto(req.Photo)
 
 	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
 	if err != nil {
@@ -50,38 +72,257 @@ func (rt *_router) sendMessage(w http.ResponseWriter, r *http.Request, ps httpro
 		return
 	}
 
-	messageId, timestamp, err := rt.db.InsertMessage(conversationId, ctx.UserID, req.Text, photoId, req.ReplyToMessageId, false)
+	threadRootId := req.ThreadRootId
+	if threadRootId == nil && req.InConversation {
+		http.Error(w, "Only thread replies can also be sent to the conversation", http.StatusBadRequest)
+		return
+	}
+	if threadRootId != nil {
+		root, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, *threadRootId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve thread root message")
+			return
+		}
+		if root == nil {
+			http.Error(w, "Thread root message not found", http.StatusNotFound)
+			return
+		}
+		if root.Deleted {
+			http.Error(w, "Deleted messages cannot be replied to in a thread", http.StatusForbidden)
+			return
+		}
+		if database.IsSystemKind(root.Kind) {
+			http.Error(w, "System messages cannot be replied to in a thread", http.StatusForbidden)
+			return
+		}
+		// Threads are one level deep: replying in the thread of a reply posts in the thread it belongs to
+		if root.ThreadRootId != nil {
+			threadRootId = root.ThreadRootId
+		}
+	}
+	inConversation := threadRootId == nil || req.InConversation
+
+	// The reply shows the content of the replied message, so it must be one the sender may see. This covers every
+	// kind of message sent below, scheduled ones included.
+	if req.ReplyToMessageId != nil {
+		replied, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, *req.ReplyToMessageId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve replied message")
+			return
+		}
+		if replied == nil {
+			http.Error(w, "Replied message not found", http.StatusNotFound)
+			return
+		}
+		if replied.Deleted {
+			http.Error(w, "Deleted messages cannot be replied to", http.StatusBadRequest)
+			return
+		}
+		if database.IsSystemKind(replied.Kind) {
+			http.Error(w, "System messages cannot be replied to", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Files can only be sent by whoever uploaded them
+	if req.FileId != nil {
+		file, err := rt.db.GetFile(*req.FileId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve file")
+			return
+		}
+		if file == nil || file.UploadedBy != ctx.UserID {
+			http.Error(w, "File not found", http.StatusBadRequest)
+			return
+		}
+		if file.IsVoiceNote() {
+			http.Error(w, "Voice notes must be sent with voiceNoteId", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.VoiceNoteId != nil {
+		file, err := rt.db.GetFile(*req.VoiceNoteId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve voice note")
+			return
+		}
+		if file == nil || file.UploadedBy != ctx.UserID || !file.IsVoiceNote() {
+			http.Error(w, "Voice note not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Poll != nil {
+		rt.sendPoll(w, ctx, conversationId, req, threadRootId, inConversation)
+		return
+	}
+	if req.VoiceNoteId != nil {
+		rt.sendVoiceNote(w, ctx, conversationId, req, threadRootId, inConversation)
+		return
+	}
+
+	if req.SendAt != nil {
+		rt.scheduleMessage(w, ctx, conversationId, req, photoId, threadRootId, inConversation)
+		return
+	}
+
+	resp, err := rt.deliverMessage(ctx, conversationId, ctx.UserID, req.Text, photoId, req.FileId, req.ReplyToMessageId, threadRootId, inConversation)
 	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to insert message")
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to send message")
 		return
 	}
 
-	participantIds, err := rt.db.GetParticipantIds(conversationId)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve participant IDs")
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
 		return
 	}
+}
+
+// deliverMessage stores a new message from senderId, creates its status for every participant and notifies them.
+func (rt *_router) deliverMessage(ctx reqcontext.RequestContext, conversationId int64, senderId int64, text *string, photoId *string, fileId *string, replyTo *int64, threadRootId *int64, inConversation bool) (dto.SentMessage, error) {
+	messageId, _, err := rt.db.InsertMessage(conversationId, senderId, text, photoId, fileId, replyTo, threadRootId, inConversation, false)
+	if err != nil {
+		return dto.SentMessage{}, fmt.Errorf("inserting message: %w", err)
+	}
+	return rt.announceMessage(ctx, conversationId, messageId, text, threadRootId)
+}
+
+// announceMessage completes the delivery of a stored message: it records its mentions, creates its status for every
+// participant and notifies them.
+func (rt *_router) announceMessage(ctx reqcontext.RequestContext, conversationId int64, messageId int64, text *string, threadRootId *int64) (dto.SentMessage, error) {
+	if err := rt.storeMentions(conversationId, messageId, text); err != nil {
+		return dto.SentMessage{}, fmt.Errorf("storing mentions: %w", err)
+	}
+
+	participantIds, err := rt.db.GetParticipantIds(conversationId)
+	if err != nil {
+		return dto.SentMessage{}, fmt.Errorf("retrieving participant IDs: %w", err)
+	}
 
 	if err := rt.db.InsertSent(messageId, conversationId, participantIds); err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to insert sent status")
+		return dto.SentMessage{}, fmt.Errorf("inserting sent status: %w", err)
+	}
+
+	dbMessage, err := rt.db.GetMessage(conversationId, messageId)
+	if err != nil {
+		return dto.SentMessage{}, fmt.Errorf("retrieving sent message: %w", err)
+	}
+
+	resp := helpers.ConvertToSentMessage(*dbMessage)
+	rt.publish(ctx, participantIds, conversationId, events.MessageCreated, resp)
+	if threadRootId != nil {
+		rt.publishThreadUpdated(ctx, conversationId, *threadRootId)
+	}
+	return resp, nil
+}
+
+// publishThreadUpdated tells the participants of a conversation the new reply count of a thread. Failures are only
+// logged, as the change that caused the update is already stored.
+func (rt *_router) publishThreadUpdated(ctx reqcontext.RequestContext, conversationId int64, rootId int64) {
+	root, err := rt.db.GetMessage(conversationId, rootId)
+	if err != nil {
+		ctx.Logger.WithError(err).Error("Failed to retrieve thread root message")
+		return
+	}
+	if root == nil {
+		return
+	}
+
+	rt.publishToConversation(ctx, conversationId, events.ThreadUpdated, dto.ThreadUpdatedEvent{
+		MessageId:        root.MessageId,
+		ThreadReplyCount: root.ThreadReplies,
+		LastReplyAt:      root.LastReplyAt,
+	})
+}
+
+// announceChange posts the system message describing a change that is already stored. The change stands even if the
+// message cannot be posted, so that failure is only logged, and ok reports whether resp holds the message.
+func (rt *_router) announceChange(ctx reqcontext.RequestContext, conversationId int64, actorId int64, kind string, data database.SystemData) (resp dto.SentMessage, ok bool) {
+	resp, err := rt.postSystemMessage(ctx, conversationId, actorId, kind, data)
+	if err != nil {
+		ctx.Logger.WithError(err).WithField("kind", kind).Error("Failed to post system message")
+		return dto.SentMessage{}, false
+	}
+	return resp, true
+}
+
+// postSystemMessage stores a system message describing an action of actorId and notifies every participant.
+func (rt *_router) postSystemMessage(ctx reqcontext.RequestContext, conversationId int64, actorId int64, kind string, data database.SystemData) (dto.SentMessage, error) {
+	messageId, err := rt.db.InsertSystemMessage(conversationId, actorId, kind, data)
+	if err != nil {
+		return dto.SentMessage{}, fmt.Errorf("inserting system message: %w", err)
+	}
+
+	dbMessage, err := rt.db.GetMessage(conversationId, messageId)
+	if err != nil {
+		return dto.SentMessage{}, fmt.Errorf("retrieving system message: %w", err)
+	}
+
+	resp := helpers.ConvertToSentMessage(*dbMessage)
+	rt.publishToConversation(ctx, conversationId, events.MessageCreated, resp)
+	return resp, nil
+}
+
+func (rt *_router) getConversationMessages(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	beforeId, err := helpers.ParseOptionalID(query, "before")
+	if err != nil {
+		http.Error(w, "Invalid before cursor", http.StatusBadRequest)
+		return
+	}
+	afterId, err := helpers.ParseOptionalID(query, "after")
+	if err != nil {
+		http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+		return
+	}
+	if beforeId != nil && afterId != nil {
+		http.Error(w, "Only one of before and after can be given", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := helpers.ParsePageLimit(query, constraints.DefaultMessagePageSize, constraints.MaxMessagePageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	dbUser, err := rt.db.GetUser(ctx.UserID)
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
 	if err != nil {
-		ctx.Logger.WithError(err).Error("Failed to get sender's user information")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	dbMessages, hasMore, err := rt.db.GetChatPage(conversationId, ctx.UserID, beforeId, afterId, limit)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve messages")
 		return
 	}
 
-	var resp dto.SentMessage
-	resp.MessageId = messageId
-	resp.ConversationId = conversationId
-	resp.Timestamp = timestamp
-	resp.Photo = Photo
-	resp.SentBy = helpers.ConvertUser(*dbUser)
-	resp.Text = req.Text
-	resp.ReplyToMessageId = req.ReplyToMessageId
-	resp.Status = "sent" // Initial status is "sent"
+	resp := dto.MessagePage{
+		Messages: helpers.ConvertToSentMessages(dbMessages),
+		HasMore:  hasMore,
+	}
+
+	// The next page continues from the oldest message when going back in time, from the newest otherwise
+	if hasMore {
+		if afterId != nil {
+			resp.NextCursor = &resp.Messages[len(resp.Messages)-1].MessageId
+		} else {
+			resp.NextCursor = &resp.Messages[0].MessageId
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(resp)
@@ -91,30 +332,169 @@ func (rt *_router) sendMessage(w http.ResponseWriter, r *http.Request, ps httpro
 	}
 }
 
-func (rt *_router) deleteMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+// getMessageThread returns a page of the replies in the thread started by a message, together with that message.
+func (rt *_router) getMessageThread(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
 	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid message ID", http.StatusBadRequest)
 		return
 	}
 
+	query := r.URL.Query()
+	beforeId, err := helpers.ParseOptionalID(query, "before")
+	if err != nil {
+		http.Error(w, "Invalid before cursor", http.StatusBadRequest)
+		return
+	}
+	afterId, err := helpers.ParseOptionalID(query, "after")
+	if err != nil {
+		http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+		return
+	}
+	if beforeId != nil && afterId != nil {
+		http.Error(w, "Only one of before and after can be given", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := helpers.ParsePageLimit(query, constraints.DefaultMessagePageSize, constraints.MaxMessagePageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	root, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if root == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	dbReplies, hasMore, err := rt.db.GetThreadPage(messageId, ctx.UserID, beforeId, afterId, limit)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve thread replies")
+		return
+	}
+
+	resp := dto.ThreadPage{
+		Root:     helpers.ConvertToSentMessage(*root),
+		Messages: helpers.ConvertToSentMessages(dbReplies),
+		HasMore:  hasMore,
+	}
+
+	if hasMore {
+		if afterId != nil {
+			resp.NextCursor = &resp.Messages[len(resp.Messages)-1].MessageId
+		} else {
+			resp.NextCursor = &resp.Messages[0].MessageId
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+func (rt *_router) getMessageContext(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
 		return
 	}
 
-	dbConversationId, err := rt.db.GetConversationIdFromMessageId(messageId)
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
 	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve conversation ID from message")
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
 		return
 	}
 
-	if dbConversationId != conversationId {
+	radius := constraints.DefaultContextRadius
+	if radiusParam := r.URL.Query().Get("radius"); radiusParam != "" {
+		radius, err = strconv.Atoi(radiusParam)
+		if err != nil || radius < 0 || radius > constraints.MaxContextRadius {
+			http.Error(w, fmt.Sprintf("Radius must be between 0 and %d", constraints.MaxContextRadius), http.StatusBadRequest)
+			return
+		}
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	dbMessages, hasMoreBefore, hasMoreAfter, err := rt.db.GetMessageContext(conversationId, ctx.UserID, messageId, radius)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message context")
+		return
+	}
+	if dbMessages == nil {
 		http.Error(w, "Message not found", http.StatusNotFound)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(dto.MessageContext{
+		Messages:        helpers.ConvertToSentMessages(dbMessages),
+		TargetMessageId: messageId,
+		HasMoreBefore:   hasMoreBefore,
+		HasMoreAfter:    hasMoreAfter,
+	})
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// deleteMessage deletes a message for everyone (the default), which only its sender can do, or with scope=me hides it
+// from the caller only, which any participant can do.
+func (rt *_router) deleteMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = "everyone"
+	}
+	if scope != "everyone" && scope != "me" {
+		http.Error(w, "Scope must be either everyone or me", http.StatusBadRequest)
+		return
+	}
+
 	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
 	if err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
@@ -126,25 +506,211 @@ func (rt *_router) deleteMessage(w http.ResponseWriter, r *http.Request, ps http
 		return
 	}
 
-	senderId, err := rt.db.GetSenderId(messageId)
+	dbMessage, err := rt.db.GetMessage(conversationId, messageId)
 	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve sender ID from message")
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
 		return
 	}
+	if dbMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	if scope == "me" {
+		if err := rt.db.HideMessage(ctx.UserID, messageId); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to hide message")
+			return
+		}
+
+		// Only the caller's other clients need to drop the message
+		rt.publish(ctx, []int64{ctx.UserID}, conversationId, events.MessageDeleted, dto.MessageDeletedEvent{MessageId: messageId})
 
-	if senderId != ctx.UserID {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if dbMessage.SentBy.UserId != ctx.UserID {
 		http.Error(w, "You are not the sender of this message", http.StatusForbidden)
 		return
 	}
+	if database.IsSystemKind(dbMessage.Kind) {
+		http.Error(w, "System messages cannot be deleted for everyone", http.StatusForbidden)
+		return
+	}
 
 	if err := rt.db.RemoveMessage(messageId); err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to delete message")
 		return
 	}
 
+	rt.publishToConversation(ctx, conversationId, events.MessageDeleted, dto.MessageDeletedEvent{MessageId: messageId, ForEveryone: true})
+	if dbMessage.ThreadRootId != nil {
+		rt.publishThreadUpdated(ctx, conversationId, *dbMessage.ThreadRootId)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (rt *_router) editMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Text) < constraints.MinMessageLength || len(req.Text) > constraints.MaxMessageLength {
+		http.Error(w, fmt.Sprintf("Message content cannot be empty and must not exceed %d characters", constraints.MaxMessageLength), http.StatusBadRequest)
+		return
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	dbMessage, err := rt.db.GetMessage(conversationId, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if dbMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	if dbMessage.SentBy.UserId != ctx.UserID {
+		http.Error(w, "You are not the sender of this message", http.StatusForbidden)
+		return
+	}
+	if dbMessage.Deleted {
+		http.Error(w, "Deleted messages cannot be edited", http.StatusForbidden)
+		return
+	}
+	if dbMessage.Kind == database.KindPoll {
+		http.Error(w, "Polls cannot be edited", http.StatusForbidden)
+		return
+	}
+	if dbMessage.Kind == database.KindVoice {
+		http.Error(w, "Voice messages cannot be edited", http.StatusForbidden)
+		return
+	}
+	if dbMessage.Kind != database.KindMessage {
+		http.Error(w, "System messages cannot be edited", http.StatusForbidden)
+		return
+	}
+	if dbMessage.IsForwarded {
+		http.Error(w, "Forwarded messages cannot be edited", http.StatusForbidden)
+		return
+	}
+
+	sentAt, err := time.Parse(time.RFC3339, dbMessage.Timestamp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to parse message timestamp")
+		return
+	}
+	if globaltime.Since(sentAt) > rt.editWindow {
+		http.Error(w, fmt.Sprintf("Messages can only be edited within %s of sending", rt.editWindow), http.StatusForbidden)
+		return
+	}
+
+	// Saving the same text again would only add a useless revision
+	if dbMessage.Text == nil || *dbMessage.Text != req.Text {
+		if _, err := rt.db.EditMessage(messageId, req.Text); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to edit message")
+			return
+		}
+
+		if err := rt.storeMentions(conversationId, messageId, &req.Text); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to store mentions")
+			return
+		}
+
+		dbMessage, err = rt.db.GetMessage(conversationId, messageId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve edited message")
+			return
+		}
+	}
+
+	resp := helpers.ConvertToSentMessage(*dbMessage)
+	rt.publishToConversation(ctx, conversationId, events.MessageEdited, resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+func (rt *_router) getMessageRevisions(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	dbMessage, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if dbMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	revisions, err := rt.db.GetMessageRevisions(messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message revisions")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"revisions": helpers.ConvertRevisions(revisions),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
 func (rt *_router) forwardMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 	var req dto.ForwardMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -184,51 +750,40 @@ func (rt *_router) forwardMessage(w http.ResponseWriter, r *http.Request, ps htt
 		return
 	}
 
-	messageId, timestamp, text, photoId, err := rt.db.ForwardMessage(req.MessageId, conversationId, ctx.UserID)
+	sourceMessage, err := rt.db.GetVisibleMessage(fromConversationId, ctx.UserID, req.MessageId)
 	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to forward message")
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message to forward")
 		return
 	}
-
-	dbUser, err := rt.db.GetUser(ctx.UserID)
-	if err != nil {
-		ctx.Logger.WithError(err).Error("Failed to get sender's user information")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if sourceMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
 		return
 	}
-
-	participantIds, err := rt.db.GetParticipantIds(conversationId)
-	if err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve participant IDs")
+	if sourceMessage.Deleted {
+		http.Error(w, "Deleted messages cannot be forwarded", http.StatusForbidden)
 		return
 	}
-
-	if err := rt.db.InsertSent(messageId, conversationId, participantIds); err != nil {
-		helpers.HandleInternalServerError(ctx, w, err, "Failed to insert sent status")
+	if sourceMessage.Kind == database.KindPoll {
+		http.Error(w, "Polls cannot be forwarded", http.StatusForbidden)
+		return
+	}
+	if database.IsSystemKind(sourceMessage.Kind) {
+		http.Error(w, "System messages cannot be forwarded", http.StatusForbidden)
 		return
 	}
 
-	var respPhoto *dto.Photo
-	if photoId != nil {
-		path, err := rt.db.GetImagePath(*photoId)
-		if err != nil {
-			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve image path")
-			return
-		}
-		respPhoto = &dto.Photo{
-			PhotoId: *photoId,
-			Path:    path,
-		}
+	messageId, _, text, _, err := rt.db.ForwardMessage(req.MessageId, conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to forward message")
+		return
 	}
 
-	var resp dto.SentMessage
-	resp.MessageId = messageId
-	resp.Timestamp = timestamp
-	resp.Photo = respPhoto
-	resp.SentBy = helpers.ConvertUser(*dbUser)
-	resp.Text = text
-	resp.ReplyToMessageId = nil
-	resp.Status = "sent" // Initial status is "sent"
+	// The copy is announced like any new message, from what was stored: its expiry, mentions and attachments included
+	resp, err := rt.announceMessage(ctx, conversationId, messageId, text, nil)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to send forwarded message")
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(resp)
@@ -273,6 +828,24 @@ func (rt *_router) commentMessage(w http.ResponseWriter, r *http.Request, ps htt
 		return
 	}
 
+	dbMessage, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if dbMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if dbMessage.Deleted {
+		http.Error(w, "Deleted messages cannot be reacted to", http.StatusForbidden)
+		return
+	}
+	if database.IsSystemKind(dbMessage.Kind) {
+		http.Error(w, "System messages cannot be reacted to", http.StatusForbidden)
+		return
+	}
+
 	err = rt.db.InsertReaction(messageId, ctx.UserID, req.Content)
 	if err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to comment on message")
@@ -287,6 +860,8 @@ func (rt *_router) commentMessage(w http.ResponseWriter, r *http.Request, ps htt
 
 	resp := helpers.ConvertReactions(reactions)
 
+	rt.publishToConversation(ctx, conversationId, events.ReactionChanged, dto.ReactionChangedEvent{MessageId: messageId, Reactions: resp})
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
@@ -318,6 +893,16 @@ func (rt *_router) uncommentMessage(w http.ResponseWriter, r *http.Request, ps h
 		return
 	}
 
+	dbMessage, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if dbMessage == nil || dbMessage.Deleted {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
 	err = rt.db.RemoveReaction(messageId, ctx.UserID)
 	if err != nil {
 		helpers.HandleInternalServerError(ctx, w, err, "Failed to remove comment from message")
@@ -332,6 +917,8 @@ func (rt *_router) uncommentMessage(w http.ResponseWriter, r *http.Request, ps h
 
 	resp := helpers.ConvertReactions(reactions)
 
+	rt.publishToConversation(ctx, conversationId, events.ReactionChanged, dto.ReactionChangedEvent{MessageId: messageId, Reactions: resp})
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Reewd/WASAproject/service/api/helpers"
 	"github.com/Reewd/WASAproject/service/api/reqcontext"
 	"github.com/julienschmidt/httprouter"
 )
@@ -11,32 +12,54 @@ import (
 func (rt *_router) idVerifierMiddleware(next httpRouterHandler) httpRouterHandler {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 		// Check if the request has a valid bearer token
-		token := r.Header.Get("Authorization")
+		token := helpers.ExtractBearerToken(r.Header.Get("Authorization"))
 		if token == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		id, err := strconv.ParseInt(token, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+		// Old clients used the user ID as the token: tell them explicitly to log in again
+		if _, err := strconv.ParseInt(token, 10, 64); err == nil {
+			http.Error(w, "User ID tokens are no longer accepted, log in again to get a session token", http.StatusUnauthorized)
 			return
 		}
 
-		exists, err := rt.db.UserExistsById(id)
+		session, err := rt.db.GetSessionByToken(token)
 		if err != nil {
-			ctx.Logger.WithError(err).Error("Failed to check user ID existence")
+			ctx.Logger.WithError(err).Error("Failed to look up session")
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		if !exists {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if session == nil {
+			http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
+			return
+		}
+
+		if err := rt.db.TouchSession(session.SessionId); err != nil {
+			ctx.Logger.WithError(err).Error("Failed to update session last use")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		ctx.UserID = id
+		ctx.UserID = session.UserId
+		ctx.SessionID = session.SessionId
 
 		next(w, r, ps, ctx)
 	}
 }
+
+// streamVerifierMiddleware is idVerifierMiddleware for the event streams. Browsers cannot set headers when opening a
+// WebSocket or an EventSource, so the token may also be sent as the access_token query parameter. Other routes do not
+// accept it, to keep tokens out of the URLs written to logs and Referer headers.
+func (rt *_router) streamVerifierMiddleware(next httpRouterHandler) httpRouterHandler {
+	verify := rt.idVerifierMiddleware(next)
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+		if r.Header.Get("Authorization") == "" {
+			if token := r.URL.Query().Get("access_token"); token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		verify(w, r, ps, ctx)
+	}
+}
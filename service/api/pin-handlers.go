@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// pinMessage pins a message of a conversation, which groups can restrict to their admins, and announces it with a
+// system message. Pinning a message that is already pinned has no effect.
+func (rt *_router) pinMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if !rt.checkGroupPermission(w, ctx, conversationId, permissionPin, "pin messages in this group") {
+		return
+	}
+
+	dbMessage, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if dbMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if dbMessage.Deleted {
+		http.Error(w, "Deleted messages cannot be pinned", http.StatusForbidden)
+		return
+	}
+	if database.IsSystemKind(dbMessage.Kind) {
+		http.Error(w, "System messages cannot be pinned", http.StatusForbidden)
+		return
+	}
+	if dbMessage.Pinned {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	pinned, err := rt.db.InsertPin(conversationId, messageId, ctx.UserID, constraints.MaxPinnedMessages)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to pin message")
+		return
+	}
+	if !pinned {
+		http.Error(w, fmt.Sprintf("A conversation can have at most %d pinned messages", constraints.MaxPinnedMessages), http.StatusConflict)
+		return
+	}
+
+	rt.publishToConversation(ctx, conversationId, events.PinChanged, dto.PinChangedEvent{MessageId: messageId, Pinned: true})
+	rt.announceChange(ctx, conversationId, ctx.UserID, database.KindPinned, database.SystemData{MessageId: &messageId})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unpinMessage unpins a message of a conversation, which groups can restrict to their admins like pinning.
+func (rt *_router) unpinMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if !rt.checkGroupPermission(w, ctx, conversationId, permissionPin, "pin messages in this group") {
+		return
+	}
+
+	unpinned, err := rt.db.RemovePin(conversationId, messageId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to unpin message")
+		return
+	}
+	if !unpinned {
+		http.Error(w, "Message is not pinned", http.StatusNotFound)
+		return
+	}
+
+	rt.publishToConversation(ctx, conversationId, events.PinChanged, dto.PinChangedEvent{MessageId: messageId, Pinned: false})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
+	"github.com/Reewd/WASAproject/service/globaltime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// parsePoll validates a poll sent by a client. Question and options are trimmed, and options must be distinct.
+func parsePoll(req dto.NewPoll) (database.NewPoll, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" || utf8.RuneCountInString(question) > constraints.MaxPollQuestionLength {
+		return database.NewPoll{}, fmt.Errorf("question cannot be empty and must not exceed %d characters", constraints.MaxPollQuestionLength)
+	}
+
+	if len(req.Options) < constraints.MinPollOptions || len(req.Options) > constraints.MaxPollOptions {
+		return database.NewPoll{}, fmt.Errorf("a poll must have between %d and %d options", constraints.MinPollOptions, constraints.MaxPollOptions)
+	}
+	options := make([]string, 0, len(req.Options))
+	seen := make(map[string]bool, len(req.Options))
+	for _, option := range req.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > constraints.MaxPollOptionLength {
+			return database.NewPoll{}, fmt.Errorf("options cannot be empty and must not exceed %d characters", constraints.MaxPollOptionLength)
+		}
+		if seen[option] {
+			return database.NewPoll{}, errors.New("options must be distinct")
+		}
+		seen[option] = true
+		options = append(options, option)
+	}
+
+	poll := database.NewPoll{
+		Question:       question,
+		Options:        options,
+		MultipleChoice: req.MultipleChoice,
+		Anonymous:      req.Anonymous,
+	}
+	if req.ClosesAt != nil {
+		closesAt, err := time.Parse(time.RFC3339, *req.ClosesAt)
+		if err != nil {
+			return database.NewPoll{}, fmt.Errorf("closesAt must be an RFC 3339 time")
+		}
+		now := globaltime.Now()
+		if !closesAt.After(now) {
+			return database.NewPoll{}, fmt.Errorf("closesAt must be in the future")
+		}
+		if closesAt.Sub(now) > constraints.MaxPollDuration {
+			return database.NewPoll{}, fmt.Errorf("closesAt must be within %d days", int(constraints.MaxPollDuration.Hours()/24))
+		}
+		poll.ClosesAt = &closesAt
+	}
+	return poll, nil
+}
+
+// sendPoll stores the poll of req as a new message and delivers it like any other message.
+func (rt *_router) sendPoll(w http.ResponseWriter, ctx reqcontext.RequestContext, conversationId int64, req dto.SendMessageRequest, threadRootId *int64, inConversation bool) {
+	poll, err := parsePoll(*req.Poll)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messageId, err := rt.db.InsertPoll(conversationId, ctx.UserID, poll, req.ReplyToMessageId, threadRootId, inConversation)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to send poll")
+		return
+	}
+
+	resp, err := rt.announceMessage(ctx, conversationId, messageId, &poll.Question, threadRootId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to send poll")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// getPollMessage returns the poll message addressed by the request path, after checking that the caller takes part in
+// its conversation. On failure it writes the error response and returns nil.
+func (rt *_router) getPollMessage(w http.ResponseWriter, ps httprouter.Params, ctx reqcontext.RequestContext) *database.MessageView {
+	conversationId, err := strconv.ParseInt(ps.ByName("conversationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return nil
+	}
+
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return nil
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return nil
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return nil
+	}
+
+	dbMessage, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return nil
+	}
+	if dbMessage == nil || dbMessage.Deleted {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return nil
+	}
+	if dbMessage.Poll == nil {
+		http.Error(w, "Message is not a poll", http.StatusBadRequest)
+		return nil
+	}
+	return dbMessage
+}
+
+// writePoll responds with the results of a poll, together with the options the caller voted for, which even
+// anonymous polls disclose to the voter.
+func (rt *_router) writePoll(w http.ResponseWriter, ctx reqcontext.RequestContext, poll dto.Poll, messageId int64) {
+	myVotes, err := rt.db.GetPollVotes(messageId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve votes")
+		return
+	}
+	poll.MyVotes = myVotes
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(poll)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// getPollVotes returns the results of a poll and the caller's own votes.
+func (rt *_router) getPollVotes(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	dbMessage := rt.getPollMessage(w, ps, ctx)
+	if dbMessage == nil {
+		return
+	}
+
+	rt.writePoll(w, ctx, *helpers.ConvertPoll(dbMessage.Poll), dbMessage.MessageId)
+}
+
+// votePoll replaces the caller's votes in a poll, and tells every participant the new results as reactions do.
+func (rt *_router) votePoll(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var req dto.VotePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dbMessage := rt.getPollMessage(w, ps, ctx)
+	if dbMessage == nil {
+		return
+	}
+	poll := dbMessage.Poll
+	if poll.Closed {
+		http.Error(w, "The poll is closed", http.StatusForbidden)
+		return
+	}
+
+	if !poll.MultipleChoice && len(req.Options) > 1 {
+		http.Error(w, "Only one option can be chosen in this poll", http.StatusBadRequest)
+		return
+	}
+	chosen := make(map[int]bool, len(req.Options))
+	for _, position := range req.Options {
+		if position < 0 || position >= len(poll.Options) {
+			http.Error(w, "Invalid option", http.StatusBadRequest)
+			return
+		}
+		if chosen[position] {
+			http.Error(w, "Options can only be chosen once", http.StatusBadRequest)
+			return
+		}
+		chosen[position] = true
+	}
+
+	if err := rt.db.SetPollVotes(dbMessage.MessageId, ctx.UserID, req.Options); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to vote")
+		return
+	}
+
+	updated, err := rt.db.GetMessage(dbMessage.ConversationId, dbMessage.MessageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve poll")
+		return
+	}
+	if updated == nil || updated.Poll == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	resp := *helpers.ConvertPoll(updated.Poll)
+
+	rt.publishToConversation(ctx, dbMessage.ConversationId, events.PollChanged, dto.PollChangedEvent{MessageId: dbMessage.MessageId, Poll: resp})
+
+	rt.writePoll(w, ctx, resp, dbMessage.MessageId)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/globaltime"
+	"github.com/julienschmidt/httprouter"
+)
+
+// parseSendAt parses the time a scheduled message is sent at, which must be in the future but not too far.
+func parseSendAt(value string) (time.Time, error) {
+	sendAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("sendAt must be an RFC 3339 time")
+	}
+
+	now := globaltime.Now()
+	if !sendAt.After(now) {
+		return time.Time{}, fmt.Errorf("sendAt must be in the future")
+	}
+	if sendAt.Sub(now) > constraints.MaxScheduleAhead {
+		return time.Time{}, fmt.Errorf("sendAt must be within %d days", int(constraints.MaxScheduleAhead.Hours()/24))
+	}
+	return sendAt, nil
+}
+
+// scheduleMessage stores a message that the scheduler sends at req.SendAt. Until then, only its sender can see it.
+func (rt *_router) scheduleMessage(w http.ResponseWriter, ctx reqcontext.RequestContext, conversationId int64, req dto.SendMessageRequest, photoId *string, threadRootId *int64, inConversation bool) {
+	sendAt, err := parseSendAt(*req.SendAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scheduledId, err := rt.db.InsertScheduledMessage(conversationId, ctx.UserID, req.Text, photoId, req.FileId, req.ReplyToMessageId, threadRootId, inConversation, sendAt)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to schedule message")
+		return
+	}
+
+	scheduled, err := rt.db.GetScheduledMessage(scheduledId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve scheduled message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(helpers.ConvertScheduledMessage(*scheduled))
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+func (rt *_router) getMyScheduledMessages(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	scheduled, err := rt.db.GetScheduledMessages(ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve scheduled messages")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"scheduledMessages": helpers.ConvertScheduledMessages(scheduled),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+func (rt *_router) rescheduleMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	scheduledId, err := strconv.ParseInt(ps.ByName("scheduledMessageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid scheduled message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.RescheduleMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sendAt, err := parseSendAt(req.SendAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	found, err := rt.db.UpdateScheduledMessageTime(scheduledId, ctx.UserID, sendAt)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to reschedule message")
+		return
+	}
+	if !found {
+		http.Error(w, "Scheduled message not found", http.StatusNotFound)
+		return
+	}
+
+	scheduled, err := rt.db.GetScheduledMessage(scheduledId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve scheduled message")
+		return
+	}
+	if scheduled == nil {
+		// Sent by the scheduler in the meantime
+		http.Error(w, "Scheduled message not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(helpers.ConvertScheduledMessage(*scheduled))
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+func (rt *_router) cancelScheduledMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	scheduledId, err := strconv.ParseInt(ps.ByName("scheduledMessageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid scheduled message ID", http.StatusBadRequest)
+		return
+	}
+
+	found, err := rt.db.RemoveUserScheduledMessage(scheduledId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to cancel scheduled message")
+		return
+	}
+	if !found {
+		http.Error(w, "Scheduled message not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/julienschmidt/httprouter"
+)
+
+// searchMessages finds the messages containing the words of q in the conversations of the caller, best match first.
+// The results can be narrowed to a conversation, to a sender given by username with from, and to the messages sent
+// before a time.
+func (rt *_router) searchMessages(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	query := r.URL.Query()
+
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" || len(text) > constraints.MaxSearchQueryLength {
+		http.Error(w, fmt.Sprintf("The search query cannot be empty and must not exceed %d characters", constraints.MaxSearchQueryLength), http.StatusBadRequest)
+		return
+	}
+
+	var filter database.SearchFilter
+
+	conversationId, err := helpers.ParseOptionalID(query, "conversationId")
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+	if conversationId != nil {
+		exists, err := rt.db.ParticipantExists(*conversationId, ctx.UserID)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+			return
+		}
+		if !exists {
+			http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+			return
+		}
+		filter.ConversationId = conversationId
+	}
+
+	if from := query.Get("from"); from != "" {
+		senderId, err := rt.db.GetUserId(from)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No user has this username", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve user")
+			return
+		}
+		filter.SenderId = &senderId
+	}
+
+	if value := query.Get("before"); value != "" {
+		before, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "before must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		filter.Before = &before
+	}
+
+	offset := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		offset, err = helpers.DecodeSearchCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit, err := helpers.ParsePageLimit(query, constraints.DefaultSearchPageSize, constraints.MaxSearchPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hits, hasMore, err := rt.db.SearchMessages(ctx.UserID, text, filter, offset, limit)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to search messages")
+		return
+	}
+
+	resp := dto.SearchResults{
+		Results: helpers.ConvertSearchHits(hits),
+		HasMore: hasMore,
+	}
+	if hasMore {
+		cursor := helpers.EncodeSearchCursor(offset + limit)
+		resp.NextCursor = &cursor
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (rt *_router) getMySessions(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	sessions, err := rt.db.GetSessions(ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve sessions")
+		return
+	}
+
+	resp := map[string][]dto.Session{
+		"sessions": helpers.ConvertSessions(sessions, ctx.SessionID),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+func (rt *_router) revokeSession(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	sessionId, err := strconv.ParseInt(ps.ByName("sessionId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := rt.db.RemoveUserSession(sessionId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to revoke session")
+		return
+	}
+
+	if !removed {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	rt.bus.EndSessions(ctx.UserID, func(id int64) bool { return id == sessionId })
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rt *_router) revokeAllSessions(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	if err := rt.db.RemoveAllSessions(ctx.UserID); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to revoke sessions")
+		return
+	}
+	rt.bus.EndSessions(ctx.UserID, func(int64) bool { return true })
+
+	w.WriteHeader(http.StatusNoContent)
+}
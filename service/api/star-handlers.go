@@ -0,0 +1,143 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/Reewd/WASAproject/service/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// starMessage stars a message for the caller only. Starring a message that is already starred has no effect.
+func (rt *_router) starMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationId, err := rt.db.GetConversationIdFromMessageId(messageId)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve conversation ID from message")
+		return
+	}
+
+	exists, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant existence")
+		return
+	}
+	if !exists {
+		http.Error(w, "You are not a participant in this conversation", http.StatusForbidden)
+		return
+	}
+
+	dbMessage, err := rt.db.GetVisibleMessage(conversationId, ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve message")
+		return
+	}
+	if dbMessage == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if dbMessage.Deleted {
+		http.Error(w, "Deleted messages cannot be starred", http.StatusForbidden)
+		return
+	}
+	if database.IsSystemKind(dbMessage.Kind) {
+		http.Error(w, "System messages cannot be starred", http.StatusForbidden)
+		return
+	}
+
+	if err := rt.db.InsertStar(ctx.UserID, messageId); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to star message")
+		return
+	}
+
+	// Stars are private, so only the caller's other sessions are told about them
+	rt.publish(ctx, []int64{ctx.UserID}, conversationId, events.StarChanged, dto.StarChangedEvent{MessageId: messageId, Starred: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unstarMessage removes a star of the caller. It works even after the caller left the conversation of the message.
+func (rt *_router) unstarMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	messageId, err := strconv.ParseInt(ps.ByName("messageId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationId, err := rt.db.GetConversationIdFromMessageId(messageId)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve conversation ID from message")
+		return
+	}
+
+	removed, err := rt.db.RemoveStar(ctx.UserID, messageId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to unstar message")
+		return
+	}
+	if !removed {
+		http.Error(w, "Message is not starred", http.StatusNotFound)
+		return
+	}
+
+	rt.publish(ctx, []int64{ctx.UserID}, conversationId, events.StarChanged, dto.StarChangedEvent{MessageId: messageId, Starred: false})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getMyStarredMessages returns, like a page of conversation messages, the messages the caller starred in the
+// conversations they are still a participant of.
+func (rt *_router) getMyStarredMessages(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	query := r.URL.Query()
+	beforeId, err := helpers.ParseOptionalID(query, "before")
+	if err != nil {
+		http.Error(w, "Invalid before cursor", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := helpers.ParsePageLimit(query, constraints.DefaultMessagePageSize, constraints.MaxMessagePageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dbMessages, hasMore, err := rt.db.GetStarredPage(ctx.UserID, beforeId, limit)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve starred messages")
+		return
+	}
+
+	resp := dto.MessagePage{
+		Messages: helpers.ConvertToSentMessages(dbMessages),
+		HasMore:  hasMore,
+	}
+	if hasMore {
+		resp.NextCursor = &resp.Messages[0].MessageId
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
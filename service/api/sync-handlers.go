@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/julienschmidt/httprouter"
+)
+
+// getSync returns the changes that happened since the cursor given in `since`, together with the current state of the
+// messages and conversations they refer to. Without `since` it only returns the current cursor, so that a client can
+// load the full state once and then synchronize incrementally from that point.
+func (rt *_router) getSync(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	resp := dto.SyncResponse{
+		Changes:       []dto.Change{},
+		Messages:      []dto.SentMessage{},
+		Conversations: []dto.ConversationPreview{},
+	}
+
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		latestId, err := rt.db.GetLatestChangeId()
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve latest change")
+			return
+		}
+		resp.Cursor = helpers.EncodeSyncCursor(latestId)
+		rt.writeSyncResponse(w, ctx, resp)
+		return
+	}
+
+	afterId, err := helpers.DecodeSyncCursor(since)
+	if err != nil {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	// Changes right after the cursor may have been pruned from the log, and could not be told apart from none
+	oldestId, err := rt.db.GetOldestChangeId()
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve oldest change")
+		return
+	}
+	if afterId < oldestId-1 {
+		http.Error(w, "The cursor is too old: load the full state again and sync without since", http.StatusGone)
+		return
+	}
+
+	changes, err := rt.db.GetChangesSince(ctx.UserID, afterId, constraints.SyncBatchSize+1)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve changes")
+		return
+	}
+
+	if len(changes) > constraints.SyncBatchSize {
+		changes = changes[:constraints.SyncBatchSize]
+		resp.HasMore = true
+	}
+
+	resp.Cursor = since
+	if len(changes) > 0 {
+		resp.Cursor = helpers.EncodeSyncCursor(changes[len(changes)-1].ChangeId)
+	}
+	resp.Changes = helpers.ConvertChanges(changes)
+
+	// Collect what the client has to refresh, each entity once
+	var messageIds []int64
+	seenMessages := make(map[int64]bool)
+	var conversationIds []int64
+	seenConversations := make(map[int64]bool)
+	for _, change := range changes {
+		switch change.Kind {
+		case database.ChangeMessageCreated, database.ChangeMessageEdited, database.ChangeReactionChanged, database.ChangeThreadUpdated, database.ChangePinChanged, database.ChangePollChanged:
+			if change.MessageId != nil && !seenMessages[*change.MessageId] {
+				seenMessages[*change.MessageId] = true
+				messageIds = append(messageIds, *change.MessageId)
+			}
+		case database.ChangeConversationUpdated, database.ChangeParticipantAdded, database.ChangeParticipantRemoved:
+			if !seenConversations[change.ConversationId] {
+				seenConversations[change.ConversationId] = true
+				conversationIds = append(conversationIds, change.ConversationId)
+			}
+		}
+	}
+
+	if len(messageIds) > 0 {
+		// Messages the user hid or may not see are left out, as in the other listings
+		databaseMessages, err := rt.db.GetVisibleMessagesByIds(ctx.UserID, messageIds)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve changed messages")
+			return
+		}
+		resp.Messages = helpers.ConvertToSentMessages(databaseMessages)
+	}
+
+	for _, conversationId := range conversationIds {
+		// Conversations the user has left only appear through their participant.removed change
+		isParticipant, err := rt.db.ParticipantExists(conversationId, ctx.UserID)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to check participant")
+			return
+		}
+		if !isParticipant {
+			continue
+		}
+
+		dbConv, err := rt.db.GetConversationById(conversationId)
+		if err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to retrieve changed conversation")
+			return
+		}
+
+		resp.Conversations = append(resp.Conversations, dto.ConversationPreview{
+			ConversationId: dbConv.ConversationId,
+			Name:           dbConv.Name,
+			Participants:   helpers.ConvertUsers(dbConv.Participants),
+			IsGroup:        dbConv.IsGroup,
+			Photo:          helpers.ConvertPhoto(dbConv.Photo),
+			MessageTtl:     dbConv.MessageTtl,
+		})
+	}
+
+	rt.writeSyncResponse(w, ctx, resp)
+}
+
+func (rt *_router) writeSyncResponse(w http.ResponseWriter, ctx reqcontext.RequestContext, resp dto.SyncResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
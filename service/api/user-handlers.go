@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/Reewd/WASAproject/service/api/constraints"
@@ -24,6 +25,24 @@ func (rt *_router) doLogin(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
+	if req.DeviceLabel != nil && len(*req.DeviceLabel) > constraints.MaxDeviceLabelLength {
+		http.Error(w, fmt.Sprintf("Device label must not exceed %d characters", constraints.MaxDeviceLabelLength), http.StatusBadRequest)
+		return
+	}
+
+	isNew, ok := rt.checkLoginCredential(w, ctx, req)
+	if !ok {
+		return
+	}
+
+	// Accounts named before usernames were restricted to the mentionable characters can still log in
+	if isNew {
+		if err := helpers.ValidateUsername(req.Username); err != nil {
+			http.Error(w, "Invalid username: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Get or create user ID
 	dbUser, err := rt.db.Login(req.Username)
 	if err != nil {
@@ -31,10 +50,35 @@ func (rt *_router) doLogin(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
-	var resp dto.User
+	// New accounts are protected by the password they are created with
+	if req.Password != nil && isNew {
+		if err := rt.setPassword(dbUser.UserId, *req.Password); err != nil {
+			helpers.HandleInternalServerError(ctx, w, err, "Failed to set password")
+			return
+		}
+	}
+
+	if err := rt.db.RemoveExpiredSessions(); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to remove expired sessions")
+		return
+	}
+
+	token, err := helpers.GenerateSessionToken()
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to generate session token")
+		return
+	}
+
+	if _, err := rt.db.InsertSession(dbUser.UserId, token, constraints.SessionDuration, req.DeviceLabel, r.UserAgent(), helpers.RemoteIP(r.RemoteAddr)); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to create session")
+		return
+	}
+
+	var resp dto.LoginResponse
 	resp.Username = req.Username
 	resp.UserId = dbUser.UserId
 	resp.Photo = helpers.ConvertPhoto(dbUser.Photo)
+	resp.Token = token
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -43,6 +87,16 @@ func (rt *_router) doLogin(w http.ResponseWriter, r *http.Request, ps httprouter
 	}
 }
 
+func (rt *_router) doLogout(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	if err := rt.db.RemoveSession(ctx.SessionID); err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Logout failed")
+		return
+	}
+	rt.bus.EndSessions(ctx.UserID, func(id int64) bool { return id == ctx.SessionID })
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (rt *_router) setMyUsername(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
 	var req dto.SetUsernameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -50,8 +104,8 @@ func (rt *_router) setMyUsername(w http.ResponseWriter, r *http.Request, ps http
 		return
 	}
 
-	if len(req.Username) < constraints.MinUsernameLength || len(req.Username) > constraints.MaxUsernameLength {
-		http.Error(w, "Username must be between 3 and 16 characters", http.StatusBadRequest)
+	if err := helpers.ValidateUsername(req.Username); err != nil {
+		http.Error(w, "Invalid username: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
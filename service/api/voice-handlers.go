@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Reewd/WASAproject/service/api/constraints"
+	"github.com/Reewd/WASAproject/service/api/dto"
+	"github.com/Reewd/WASAproject/service/api/helpers"
+	"github.com/Reewd/WASAproject/service/api/reqcontext"
+	"github.com/Reewd/WASAproject/service/audio"
+	"github.com/Reewd/WASAproject/service/database"
+	"github.com/julienschmidt/httprouter"
+)
+
+// uploadVoiceNote stores an Ogg/Opus or M4A recording that the caller can then send as a voice message, together with
+// its duration and waveform.
+func (rt *_router) uploadVoiceNote(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	file, handler, done, ok := rt.receiveUpload(w, r, ctx)
+	if !ok {
+		return
+	}
+	defer done()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to read voice note")
+		return
+	}
+
+	info, err := audio.Parse(data, constraints.VoiceWaveformSamples)
+	if errors.Is(err, audio.ErrUnsupportedFormat) {
+		http.Error(w, "Voice notes must be Ogg/Opus or M4A audio", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid audio file", http.StatusBadRequest)
+		return
+	}
+	if info.Duration > constraints.MaxVoiceNoteDuration {
+		http.Error(w, fmt.Sprintf("Voice notes must not be longer than %d minutes", int(constraints.MaxVoiceNoteDuration.Minutes())), http.StatusBadRequest)
+		return
+	}
+
+	durationMs := info.Duration.Milliseconds()
+	dbFile, err := rt.storeAttachment(ctx, bytes.NewReader(data), database.File{
+		Filename:   helpers.SanitizeFilename(handler.Filename),
+		MimeType:   info.MimeType,
+		UploadedBy: ctx.UserID,
+		DurationMs: &durationMs,
+		Waveform:   info.Waveform,
+	})
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to save voice note")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(helpers.ConvertVoiceNote(dbFile))
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
+
+// sendVoiceNote sends the voice note of req, which the caller has checked, as a new voice message and delivers it
+// like any other message.
+func (rt *_router) sendVoiceNote(w http.ResponseWriter, ctx reqcontext.RequestContext, conversationId int64, req dto.SendMessageRequest, threadRootId *int64, inConversation bool) {
+	messageId, err := rt.db.InsertVoiceMessage(conversationId, ctx.UserID, *req.VoiceNoteId, req.ReplyToMessageId, threadRootId, inConversation)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to send voice message")
+		return
+	}
+
+	resp, err := rt.announceMessage(ctx, conversationId, messageId, nil, threadRootId)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to send voice message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		helpers.HandleInternalServerError(ctx, w, err, "Failed to encode JSON response")
+		return
+	}
+}
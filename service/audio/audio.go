@@ -0,0 +1,109 @@
+/*
+Package audio reads the metadata of voice notes from their container, without decoding the audio itself.
+
+Two formats are supported: Opus in an Ogg container, and AAC in an MP4 container (M4A). Parse returns the duration
+of a recording and a waveform for drawing it. As the audio is not decoded, the waveform is estimated from the size of
+the compressed packets: both codecs are usually encoded with a variable bitrate, which spends more bytes on louder and
+busier passages and very few on silence.
+*/
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// ErrUnsupportedFormat is returned for data that is neither Ogg/Opus nor M4A audio.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// errMalformed is returned for files whose container cannot be read.
+var errMalformed = errors.New("malformed audio file")
+
+// Info is the metadata of a voice note.
+type Info struct {
+	MimeType string
+	Duration time.Duration
+	Waveform []byte // one value per sample, from 0 for the quietest to 255 for the loudest
+}
+
+// Parse reads the metadata of a voice note, with a waveform of the given number of samples.
+func Parse(data []byte, samples int) (*Info, error) {
+	var (
+		info        Info
+		packetSizes []int
+		err         error
+	)
+	switch {
+	case bytes.HasPrefix(data, []byte("OggS")):
+		info.MimeType = "audio/ogg"
+		info.Duration, packetSizes, err = parseOgg(data)
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		info.MimeType = "audio/mp4"
+		info.Duration, packetSizes, err = parseMP4(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Duration <= 0 || len(packetSizes) == 0 {
+		return nil, errMalformed
+	}
+
+	info.Waveform = waveform(packetSizes, samples)
+	return &info, nil
+}
+
+// maxDuration caps the durations read from containers. It is far longer than any voice note, and keeps durations
+// from overflowing.
+const maxDuration = 24 * time.Hour
+
+// toDuration converts a number of units counted at the given rate per second to a duration, at most maxDuration.
+func toDuration(units uint64, rate uint64) time.Duration {
+	if units/rate >= uint64(maxDuration/time.Second) {
+		return maxDuration
+	}
+	return time.Duration(units/rate)*time.Second + time.Duration(units%rate)*time.Second/time.Duration(rate)
+}
+
+// waveform downsamples the sizes of the packets of a recording, assumed to last the same time each, to the given
+// number of samples, scaled between the smallest and the largest sample.
+func waveform(packetSizes []int, samples int) []byte {
+	averages := make([]float64, samples)
+	for i := range averages {
+		start := i * len(packetSizes) / samples
+		end := (i + 1) * len(packetSizes) / samples
+		if end <= start {
+			// Fewer packets than samples: repeat the packet
+			end = start + 1
+		}
+
+		total := 0
+		for _, size := range packetSizes[start:end] {
+			total += size
+		}
+		averages[i] = float64(total) / float64(end-start)
+	}
+
+	lowest, highest := averages[0], averages[0]
+	for _, average := range averages {
+		if average < lowest {
+			lowest = average
+		}
+		if average > highest {
+			highest = average
+		}
+	}
+
+	values := make([]byte, samples)
+	for i, average := range averages {
+		if highest == lowest {
+			// A constant bitrate tells nothing about the loudness
+			values[i] = 255
+			continue
+		}
+		values[i] = byte((average - lowest) / (highest - lowest) * 255)
+	}
+	return values
+}
@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantMimeType string
+	}{
+		{"ogg", testOgg, "audio/ogg"},
+		{"m4a", testM4A, "audio/mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Parse(tt.data, 3)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if info.MimeType != tt.wantMimeType {
+				t.Errorf("MimeType = %q, want %q", info.MimeType, tt.wantMimeType)
+			}
+			if info.Duration != 3*time.Second {
+				t.Errorf("Duration = %v, want 3s", info.Duration)
+			}
+			// Both files hold packets of 10, 300 and 20 bytes
+			if want := []byte{0, 255, 8}; !bytes.Equal(info.Waveform, want) {
+				t.Errorf("Waveform = %v, want %v", info.Waveform, want)
+			}
+		})
+	}
+}
+
+func TestParseUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrUnsupportedFormat},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ErrUnsupportedFormat},
+		{"mp3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), ErrUnsupportedFormat},
+		{"short ftyp", []byte("\x00\x00\x00"), ErrUnsupportedFormat},
+		{"ogg without audio packets", oggHeaders(0), errMalformed},
+		{"m4a without samples", m4a(track(mdhdV0(1000, 1000), "soun", "mp4a", stszSizes())), errMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Parse(tt.data, 10)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse error = %v, want %v", err, tt.want)
+			}
+			if info != nil {
+				t.Errorf("Parse returned %+v with an error", info)
+			}
+		})
+	}
+}
+
+func TestWaveform(t *testing.T) {
+	tests := []struct {
+		name        string
+		packetSizes []int
+		samples     int
+		want        []byte
+	}{
+		{"one packet per sample", []int{10, 20, 30}, 3, []byte{0, 127, 255}},
+		{"averaged packets", []int{10, 30, 50, 50, 0, 0}, 3, []byte{102, 255, 0}},
+		{"fewer packets than samples", []int{10, 20}, 4, []byte{0, 0, 255, 255}},
+		{"constant bitrate", []int{40, 40, 40, 40}, 2, []byte{255, 255}},
+		{"single packet", []int{7}, 3, []byte{255, 255, 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := waveform(tt.packetSizes, tt.samples); !bytes.Equal(got, tt.want) {
+				t.Errorf("waveform(%v, %d) = %v, want %v", tt.packetSizes, tt.samples, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToDuration(t *testing.T) {
+	tests := []struct {
+		units uint64
+		rate  uint64
+		want  time.Duration
+	}{
+		{0, 48000, 0},
+		{48000, 48000, time.Second},
+		{72000, 48000, 1500 * time.Millisecond},
+		{1, 3, time.Second / 3},
+		{uint64(24 * 3600), 1, maxDuration},
+		{1 << 63, 1, maxDuration},
+		{1<<64 - 1, 1000, maxDuration},
+	}
+	for _, tt := range tests {
+		if got := toDuration(tt.units, tt.rate); got != tt.want {
+			t.Errorf("toDuration(%d, %d) = %v, want %v", tt.units, tt.rate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package audio
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// box is an MP4 box, holding either data or other boxes in its payload.
+type box struct {
+	kind    string
+	payload []byte
+}
+
+// readBoxes splits data into the boxes it is made of.
+func readBoxes(data []byte) ([]box, error) {
+	var boxes []box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errMalformed
+		}
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		kind := string(data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			// The box extends to the end of the file
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, errMalformed
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return nil, errMalformed
+		}
+
+		boxes = append(boxes, box{kind: kind, payload: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+// findBox returns the payload of the box reached from data by following the given path of box kinds, taking the
+// first box of each kind.
+func findBox(data []byte, path ...string) ([]byte, error) {
+	for _, kind := range path {
+		boxes, err := readBoxes(data)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, b := range boxes {
+			if b.kind == kind {
+				data = b.payload
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errMalformed
+		}
+	}
+	return data, nil
+}
+
+// parseMP4 reads an MP4 file holding a single AAC audio track. It returns the duration of the track and the sizes of
+// its samples. Files with a video track are not voice notes and are rejected.
+func parseMP4(data []byte) (time.Duration, []int, error) {
+	moov, err := findBox(data, "moov")
+	if err != nil {
+		return 0, nil, err
+	}
+	boxes, err := readBoxes(moov)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var audioTrack []byte
+	for _, b := range boxes {
+		if b.kind != "trak" {
+			continue
+		}
+		hdlr, err := findBox(b.payload, "mdia", "hdlr")
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(hdlr) < 12 {
+			return 0, nil, errMalformed
+		}
+		switch string(hdlr[8:12]) {
+		case "vide":
+			return 0, nil, ErrUnsupportedFormat
+		case "soun":
+			if audioTrack != nil {
+				return 0, nil, ErrUnsupportedFormat
+			}
+			audioTrack = b.payload
+		}
+	}
+	if audioTrack == nil {
+		return 0, nil, ErrUnsupportedFormat
+	}
+
+	stsd, err := findBox(audioTrack, "mdia", "minf", "stbl", "stsd")
+	if err != nil {
+		return 0, nil, err
+	}
+	// The sample description follows the version, flags and entry count
+	if len(stsd) < 16 {
+		return 0, nil, errMalformed
+	}
+	if string(stsd[12:16]) != "mp4a" {
+		return 0, nil, ErrUnsupportedFormat
+	}
+
+	mdhd, err := findBox(audioTrack, "mdia", "mdhd")
+	if err != nil {
+		return 0, nil, err
+	}
+	duration, err := parseMediaHeader(mdhd)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	stsz, err := findBox(audioTrack, "mdia", "minf", "stbl", "stsz")
+	if err != nil {
+		return 0, nil, err
+	}
+	sampleSizes, err := parseSampleSizes(stsz, len(data))
+	if err != nil {
+		return 0, nil, err
+	}
+	return duration, sampleSizes, nil
+}
+
+// parseMediaHeader reads the duration of a track from its mdhd box, whose layout depends on its version.
+func parseMediaHeader(mdhd []byte) (time.Duration, error) {
+	if len(mdhd) < 1 {
+		return 0, errMalformed
+	}
+
+	var timescale, duration uint64
+	switch mdhd[0] {
+	case 0:
+		if len(mdhd) < 20 {
+			return 0, errMalformed
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mdhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mdhd[16:20]))
+		if duration == 0xFFFFFFFF {
+			duration = 0
+		}
+	case 1:
+		if len(mdhd) < 32 {
+			return 0, errMalformed
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mdhd[20:24]))
+		duration = binary.BigEndian.Uint64(mdhd[24:32])
+		if duration == 0xFFFFFFFFFFFFFFFF {
+			duration = 0
+		}
+	default:
+		return 0, errMalformed
+	}
+
+	// Fragmented files leave the duration unknown
+	if timescale == 0 || duration == 0 {
+		return 0, errMalformed
+	}
+	return toDuration(duration, timescale), nil
+}
+
+// parseSampleSizes reads the sizes of the samples of a track from its stsz box. Every sample takes at least a byte,
+// so a file of fileSize bytes cannot have more samples than that.
+func parseSampleSizes(stsz []byte, fileSize int) ([]int, error) {
+	if len(stsz) < 12 {
+		return nil, errMalformed
+	}
+	sampleSize := binary.BigEndian.Uint32(stsz[4:8])
+	count := uint64(binary.BigEndian.Uint32(stsz[8:12]))
+	if count > uint64(fileSize) {
+		return nil, errMalformed
+	}
+
+	sizes := make([]int, count)
+	if sampleSize != 0 {
+		// All the samples have the same size, which is not listed
+		for i := range sizes {
+			sizes[i] = int(sampleSize)
+		}
+		return sizes, nil
+	}
+
+	if uint64(len(stsz)-12) < 4*count {
+		return nil, errMalformed
+	}
+	for i := range sizes {
+		sizes[i] = int(binary.BigEndian.Uint32(stsz[12+4*i : 16+4*i]))
+	}
+	return sizes, nil
+}
@@ -0,0 +1,178 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mp4Box builds an MP4 box holding the concatenation of the given contents.
+func mp4Box(kind string, contents ...[]byte) []byte {
+	payload := concat(contents...)
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b[0:4], uint32(8+len(payload)))
+	copy(b[4:8], kind)
+	return append(b, payload...)
+}
+
+func be32(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+func be64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// mdhdV0 builds a version 0 media header.
+func mdhdV0(timescale, duration uint32) []byte {
+	return mp4Box("mdhd", be32(0, 0, 0, timescale, duration, 0))
+}
+
+// mdhdV1 builds a version 1 media header, with 64-bit times.
+func mdhdV1(timescale uint32, duration uint64) []byte {
+	return mp4Box("mdhd", be32(1<<24), be64(0), be64(0), be32(timescale), be64(duration), be32(0))
+}
+
+func hdlr(handler string) []byte {
+	return mp4Box("hdlr", be32(0, 0), []byte(handler), make([]byte, 12), []byte("\x00"))
+}
+
+func stsd(codec string) []byte {
+	return mp4Box("stsd", be32(0, 1, 36), []byte(codec), make([]byte, 28))
+}
+
+// stszSizes builds a sample size box listing the size of each sample.
+func stszSizes(sizes ...uint32) []byte {
+	return mp4Box("stsz", be32(0, 0, uint32(len(sizes))), be32(sizes...))
+}
+
+func track(mdhd []byte, handler string, codec string, stsz []byte) []byte {
+	return mp4Box("trak", mp4Box("mdia", mdhd, hdlr(handler), mp4Box("minf", mp4Box("stbl", stsd(codec), stsz))))
+}
+
+var ftyp = mp4Box("ftyp", []byte("M4A "), be32(0), []byte("M4A isom"))
+
+func m4a(tracks ...[]byte) []byte {
+	return concat(ftyp, mp4Box("moov", tracks...))
+}
+
+// testM4A is a three second AAC track of three samples.
+var testM4A = m4a(track(mdhdV0(44100, 3*44100), "soun", "mp4a", stszSizes(10, 300, 20)))
+
+func TestParseMP4(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantDuration time.Duration
+		wantSizes    []int
+	}{
+		{
+			name:         "sample size table",
+			data:         testM4A,
+			wantDuration: 3 * time.Second,
+			wantSizes:    []int{10, 300, 20},
+		},
+		{
+			name:         "constant sample size",
+			data:         m4a(track(mdhdV0(1000, 1500), "soun", "mp4a", mp4Box("stsz", be32(0, 200, 4)))),
+			wantDuration: 1500 * time.Millisecond,
+			wantSizes:    []int{200, 200, 200, 200},
+		},
+		{
+			name:         "64-bit media header",
+			data:         m4a(track(mdhdV1(48000, 2*48000), "soun", "mp4a", stszSizes(5, 6))),
+			wantDuration: 2 * time.Second,
+			wantSizes:    []int{5, 6},
+		},
+		{
+			name:         "metadata track",
+			data:         m4a(track(mdhdV0(1000, 1000), "meta", "mp4a", stszSizes(1)), track(mdhdV0(1000, 2000), "soun", "mp4a", stszSizes(7))),
+			wantDuration: 2 * time.Second,
+			wantSizes:    []int{7},
+		},
+		{
+			name: "box extending to the end of the file",
+			data: func() []byte {
+				data := m4a(track(mdhdV0(1000, 1000), "soun", "mp4a", stszSizes(9)))
+				binary.BigEndian.PutUint32(data[len(ftyp):], 0)
+				return data
+			}(),
+			wantDuration: time.Second,
+			wantSizes:    []int{9},
+		},
+		{
+			name:         "duration beyond the cap",
+			data:         m4a(track(mdhdV1(1, 1<<62), "soun", "mp4a", stszSizes(9))),
+			wantDuration: maxDuration,
+			wantSizes:    []int{9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duration, sizes, err := parseMP4(tt.data)
+			if err != nil {
+				t.Fatalf("parseMP4: %v", err)
+			}
+			if duration != tt.wantDuration {
+				t.Errorf("duration = %v, want %v", duration, tt.wantDuration)
+			}
+			if !equalInts(sizes, tt.wantSizes) {
+				t.Errorf("sample sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+		})
+	}
+}
+
+func TestParseMP4Invalid(t *testing.T) {
+	audio := track(mdhdV0(1000, 1000), "soun", "mp4a", stszSizes(9))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"no movie", ftyp, errMalformed},
+		{"no track", m4a(), ErrUnsupportedFormat},
+		{"video track", m4a(audio, track(mdhdV0(1000, 1000), "vide", "avc1", stszSizes(9))), ErrUnsupportedFormat},
+		{"two audio tracks", m4a(audio, audio), ErrUnsupportedFormat},
+		{"other codec", m4a(track(mdhdV0(1000, 1000), "soun", "Opus", stszSizes(9))), ErrUnsupportedFormat},
+		{"track without media", m4a(mp4Box("trak", mp4Box("tkhd", make([]byte, 84)))), errMalformed},
+		{"short handler", m4a(mp4Box("trak", mp4Box("mdia", mp4Box("hdlr", be32(0))))), errMalformed},
+		{"no sample sizes", m4a(mp4Box("trak", mp4Box("mdia", mdhdV0(1000, 1000), hdlr("soun"), mp4Box("minf", mp4Box("stbl", stsd("mp4a")))))), errMalformed},
+		{"unknown duration", m4a(track(mdhdV0(1000, 0xFFFFFFFF), "soun", "mp4a", stszSizes(9))), errMalformed},
+		{"unknown 64-bit duration", m4a(track(mdhdV1(1000, 0xFFFFFFFFFFFFFFFF), "soun", "mp4a", stszSizes(9))), errMalformed},
+		{"zero timescale", m4a(track(mdhdV0(0, 1000), "soun", "mp4a", stszSizes(9))), errMalformed},
+		{"unknown media header version", m4a(track(mp4Box("mdhd", be32(2<<24, 0, 0, 1000, 1000, 0)), "soun", "mp4a", stszSizes(9))), errMalformed},
+		{"short media header", m4a(track(mp4Box("mdhd", be32(0, 0, 0)), "soun", "mp4a", stszSizes(9))), errMalformed},
+		{"more samples than bytes", m4a(track(mdhdV0(1000, 1000), "soun", "mp4a", mp4Box("stsz", be32(0, 1, 1<<30)))), errMalformed},
+		{"sample table cut short", m4a(track(mdhdV0(1000, 1000), "soun", "mp4a", mp4Box("stsz", be32(0, 0, 3, 10)))), errMalformed},
+		{"box smaller than its header", concat(ftyp, be32(4), []byte("moov")), errMalformed},
+		{"box larger than the file", concat(ftyp, be32(1000), []byte("moov")), errMalformed},
+		{"64-bit size cut short", concat(ftyp, be32(1), []byte("moov"), be32(0)), errMalformed},
+		{"64-bit size beyond the file", concat(ftyp, be32(1), []byte("moov"), be64(1<<40)), errMalformed},
+		{"trailing bytes", concat(testM4A, []byte("xyz")), errMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseMP4(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("parseMP4 error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseMP4Truncated checks that every truncation of a file is rejected rather than read past its end.
+func TestParseMP4Truncated(t *testing.T) {
+	for n := 0; n < len(testM4A); n++ {
+		if _, _, err := parseMP4(testM4A[:n]); err == nil {
+			t.Errorf("parseMP4 accepted the first %d of %d bytes", n, len(testM4A))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// opusSampleRate is the rate at which Opus granule positions count samples, whatever the rate of the input was.
+const opusSampleRate = 48000
+
+// parseOgg reads an Ogg file holding an Opus stream. It returns the duration of the stream and the sizes of its audio
+// packets. Pages of other logical streams, e.g. a cover picture, are ignored.
+func parseOgg(data []byte) (time.Duration, []int, error) {
+	var (
+		serial      uint32
+		preSkip     uint64
+		lastGranule int64 = -1
+		packets     int   // complete packets read so far, the first two being the Opus headers
+		packetSize  int   // size of the packet being read, which may continue on the next page
+		packetSizes []int
+	)
+
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 27 || !bytes.Equal(data[offset:offset+4], []byte("OggS")) {
+			return 0, nil, errMalformed
+		}
+		header := data[offset : offset+27]
+		granule := int64(binary.LittleEndian.Uint64(header[6:14]))
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		segments := int(header[26])
+		if len(data)-offset < 27+segments {
+			return 0, nil, errMalformed
+		}
+		lacing := data[offset+27 : offset+27+segments]
+
+		body := offset + 27 + segments
+		bodySize := 0
+		for _, value := range lacing {
+			bodySize += int(value)
+		}
+		if len(data)-body < bodySize {
+			return 0, nil, errMalformed
+		}
+
+		if offset == 0 {
+			serial = pageSerial
+			// The identification header is alone on the first page
+			head := data[body : body+bodySize]
+			if len(head) < 19 || !bytes.HasPrefix(head, []byte("OpusHead")) {
+				return 0, nil, ErrUnsupportedFormat
+			}
+			preSkip = uint64(binary.LittleEndian.Uint16(head[10:12]))
+		}
+
+		if pageSerial == serial {
+			for _, value := range lacing {
+				packetSize += int(value)
+				// A lacing value of 255 means that the packet continues in the next segment
+				if value < 255 {
+					if packets >= 2 {
+						packetSizes = append(packetSizes, packetSize)
+					}
+					packets++
+					packetSize = 0
+				}
+			}
+			// Pages on which no packet ends have no granule position
+			if granule != -1 {
+				lastGranule = granule
+			}
+		}
+
+		offset = body + bodySize
+	}
+
+	if lastGranule < 0 || uint64(lastGranule) <= preSkip {
+		return 0, nil, errMalformed
+	}
+	return toDuration(uint64(lastGranule)-preSkip, opusSampleRate), packetSizes, nil
+}
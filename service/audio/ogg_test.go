@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// oggPage builds an Ogg page of a logical stream holding the given lacing values and body. The checksum is left
+// empty, as the parser does not verify it.
+func oggPage(serial uint32, granule int64, lacing []byte, body []byte) []byte {
+	page := make([]byte, 27, 27+len(lacing)+len(body))
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:18], serial)
+	page[26] = byte(len(lacing))
+	page = append(page, lacing...)
+	return append(page, body...)
+}
+
+// oggLacing returns the lacing values of a packet of the given size that ends on its page.
+func oggLacing(size int) []byte {
+	lacing := make([]byte, size/255, size/255+1)
+	for i := range lacing {
+		lacing[i] = 255
+	}
+	return append(lacing, byte(size%255))
+}
+
+// oggPackets builds a page on which the packets of the given sizes start and end.
+func oggPackets(serial uint32, granule int64, sizes ...int) []byte {
+	var lacing, body []byte
+	for _, size := range sizes {
+		lacing = append(lacing, oggLacing(size)...)
+		body = append(body, make([]byte, size)...)
+	}
+	return oggPage(serial, granule, lacing, body)
+}
+
+// opusHead builds an Opus identification header with the given pre-skip.
+func opusHead(preSkip uint16) []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = 1 // channels
+	binary.LittleEndian.PutUint16(head[10:12], preSkip)
+	binary.LittleEndian.PutUint32(head[12:16], 48000)
+	return head
+}
+
+const testSerial = 0x1234
+
+// oggHeaders builds the two header pages of an Opus stream.
+func oggHeaders(preSkip uint16) []byte {
+	head := opusHead(preSkip)
+	data := oggPage(testSerial, 0, oggLacing(len(head)), head)
+	return append(data, oggPackets(testSerial, 0, 30)...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+// testOgg is a three second Opus stream of three packets.
+var testOgg = concat(oggHeaders(312), oggPackets(testSerial, 312+3*48000, 10, 300, 20))
+
+func TestParseOgg(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		wantDuration time.Duration
+		wantSizes    []int
+	}{
+		{
+			name:         "one audio page",
+			data:         testOgg,
+			wantDuration: 3 * time.Second,
+			wantSizes:    []int{10, 300, 20},
+		},
+		{
+			name: "several audio pages",
+			data: concat(oggHeaders(0),
+				oggPackets(testSerial, 48000, 40, 50),
+				oggPackets(testSerial, 72000, 60)),
+			wantDuration: 1500 * time.Millisecond,
+			wantSizes:    []int{40, 50, 60},
+		},
+		{
+			name: "packet continued on the next page",
+			data: concat(oggHeaders(0),
+				oggPage(testSerial, -1, []byte{255}, make([]byte, 255)),
+				oggPage(testSerial, 24000, []byte{45, 10}, make([]byte, 55))),
+			wantDuration: 500 * time.Millisecond,
+			wantSizes:    []int{300, 10},
+		},
+		{
+			name: "other logical stream",
+			data: concat(oggHeaders(0),
+				oggPackets(testSerial+1, 10*48000, 1000),
+				oggPackets(testSerial, 48000, 70)),
+			wantDuration: time.Second,
+			wantSizes:    []int{70},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duration, sizes, err := parseOgg(tt.data)
+			if err != nil {
+				t.Fatalf("parseOgg: %v", err)
+			}
+			if duration != tt.wantDuration {
+				t.Errorf("duration = %v, want %v", duration, tt.wantDuration)
+			}
+			if !equalInts(sizes, tt.wantSizes) {
+				t.Errorf("packet sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+		})
+	}
+}
+
+func TestParseOggInvalid(t *testing.T) {
+	vorbis := make([]byte, 30)
+	copy(vorbis, "\x01vorbis")
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, errMalformed},
+		{"not ogg", []byte("RIFF....WAVEfmt "), errMalformed},
+		{"vorbis stream", concat(oggPage(testSerial, 0, oggLacing(len(vorbis)), vorbis)), ErrUnsupportedFormat},
+		{"short opus header", oggPage(testSerial, 0, oggLacing(12), opusHead(0)[:12]), ErrUnsupportedFormat},
+		{"headers only", oggHeaders(0), errMalformed},
+		{"ends within pre-skip", concat(oggHeaders(3840), oggPackets(testSerial, 3840, 10)), errMalformed},
+		{"no granule position", concat(oggHeaders(0), oggPage(testSerial, -1, []byte{255}, make([]byte, 255))), errMalformed},
+		{"trailing garbage", concat(testOgg, []byte("garbage")), errMalformed},
+		{"lacing beyond the end", concat(oggHeaders(0), oggPage(testSerial, 48000, []byte{100}, make([]byte, 50))), errMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseOgg(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("parseOgg error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseOggTruncated checks that every truncation of a stream is rejected rather than read past its end.
+func TestParseOggTruncated(t *testing.T) {
+	for n := 0; n < len(testOgg); n++ {
+		if _, _, err := parseOgg(testOgg[:n]); err == nil {
+			t.Errorf("parseOgg accepted the first %d of %d bytes", n, len(testOgg))
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -37,7 +37,8 @@ import (
 	"os"
 )
 
-// NewAppDatabase reads initdb.sql, applies schema, and returns an AppDatabase.
+// NewAppDatabase reads initdb.sql, migrates the tables created by earlier versions, applies schema, and returns an
+// AppDatabase.
 func New(db *sql.DB) (AppDatabase, error) {
 	if db == nil {
 		return nil, errors.New("db is nil")
@@ -48,12 +49,21 @@ func New(db *sql.DB) (AppDatabase, error) {
 		return nil, fmt.Errorf("error reading initdb.sql: %w", err)
 	}
 
+	if err := migrate(db, string(initdbBytes)); err != nil {
+		return nil, fmt.Errorf("error migrating the database: %w", err)
+	}
+
 	_, err = db.Exec(string(initdbBytes))
 	if err != nil {
 		return nil, fmt.Errorf("error executing initdb.sql: %w", err)
 	}
 
-	return &appdbimpl{c: db}, nil
+	fts, err := initSearchIndex(db)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the search index: %w", err)
+	}
+
+	return &appdbimpl{c: db, fts: fts}, nil
 }
 
 // Ping checks if the DB connection is alive.
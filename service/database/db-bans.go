@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// InsertBan keeps userId from being added back to a group, on behalf of bannedBy. Banning a user again replaces the
+// previous ban.
+func (db *appdbimpl) InsertBan(conversationId int64, userId int64, bannedBy int64) error {
+	stmt := `INSERT INTO group_bans (conversationId, userId, bannedBy) VALUES (?, ?, ?)
+			 ON CONFLICT (conversationId, userId) DO UPDATE SET bannedBy = excluded.bannedBy, bannedAt = CURRENT_TIMESTAMP`
+	_, err := db.c.Exec(stmt, conversationId, userId, bannedBy)
+	return err
+}
+
+// RemoveBan lifts the ban of userId from a group, and reports whether they were banned.
+func (db *appdbimpl) RemoveBan(conversationId int64, userId int64) (bool, error) {
+	result, err := db.c.Exec(`DELETE FROM group_bans WHERE conversationId = ? AND userId = ?`, conversationId, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetBans returns the users banned from a group, the most recently banned first.
+func (db *appdbimpl) GetBans(conversationId int64) ([]Ban, error) {
+	stmt := `SELECT b.bannedAt, u.id, u.username, u.photoId, i.path, bu.id, bu.username, bu.photoId, bi.path
+			 FROM group_bans b
+			 JOIN users u ON b.userId = u.id
+			 LEFT JOIN images i ON u.photoId = i.uuid
+			 JOIN users bu ON b.bannedBy = bu.id
+			 LEFT JOIN images bi ON bu.photoId = bi.uuid
+			 WHERE b.conversationId = ?
+			 ORDER BY b.bannedAt DESC, b.rowid DESC`
+	rows, err := db.c.Query(stmt, conversationId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var bans []Ban
+	for rows.Next() {
+		var ban Ban
+		var nsPhotoId, nsPhotoPath, nsBannedByPhotoId, nsBannedByPhotoPath sql.NullString
+		err := rows.Scan(&ban.BannedAt, &ban.User.UserId, &ban.User.Username, &nsPhotoId, &nsPhotoPath,
+			&ban.BannedBy.UserId, &ban.BannedBy.Username, &nsBannedByPhotoId, &nsBannedByPhotoPath)
+		if err != nil {
+			return nil, err
+		}
+		if nsPhotoId.Valid && nsPhotoPath.Valid {
+			ban.User.Photo = &Photo{PhotoId: nsPhotoId.String, Path: nsPhotoPath.String}
+		}
+		if nsBannedByPhotoId.Valid && nsBannedByPhotoPath.Valid {
+			ban.BannedBy.Photo = &Photo{PhotoId: nsBannedByPhotoId.String, Path: nsBannedByPhotoPath.String}
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+// IsBanned reports whether userId is banned from a group.
+func (db *appdbimpl) IsBanned(conversationId int64, userId int64) (bool, error) {
+	stmt := `SELECT EXISTS (SELECT 1 FROM group_bans WHERE conversationId = ? AND userId = ?)`
+	var banned bool
+	err := db.c.QueryRow(stmt, conversationId, userId).Scan(&banned)
+	return banned, err
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// Kinds of entries in the change log. The log is written by the methods that modify the data, and read by clients
+// that synchronize incrementally.
+const (
+	ChangeMessageCreated      = "message.created"
+	ChangeMessageEdited       = "message.edited"
+	ChangeMessageDeleted      = "message.deleted"
+	ChangeReactionChanged     = "reaction.changed"
+	ChangeThreadUpdated       = "thread.updated"
+	ChangePinChanged          = "pin.changed"
+	ChangePollChanged         = "poll.changed"
+	ChangeStatusChanged       = "status.changed"
+	ChangeConversationUpdated = "conversation.updated"
+	ChangeParticipantAdded    = "participant.added"
+	ChangeParticipantRemoved  = "participant.removed"
+)
+
+// recordChange appends an entry to the change log. messageId and userId are optional and depend on the kind.
+func (db *appdbimpl) recordChange(conversationId int64, kind string, messageId *int64, userId *int64) error {
+	return recordChangeOn(db.c, conversationId, kind, messageId, userId)
+}
+
+// recordChangeOn is recordChange run on q, which may be a transaction.
+func recordChangeOn(q querier, conversationId int64, kind string, messageId *int64, userId *int64) error {
+	stmt := `INSERT INTO changes (conversationId, kind, messageId, userId) VALUES (?, ?, ?, ?)`
+	_, err := q.Exec(stmt, conversationId, kind, messageId, userId)
+	return err
+}
+
+// recordMessageChange appends an entry about a message to the change log, looking up its conversation.
+func (db *appdbimpl) recordMessageChange(kind string, messageId int64, userId *int64) error {
+	return recordMessageChangeOn(db.c, kind, messageId, userId)
+}
+
+// recordMessageChangeOn is recordMessageChange run on q, which may be a transaction.
+func recordMessageChangeOn(q querier, kind string, messageId int64, userId *int64) error {
+	stmt := `INSERT INTO changes (conversationId, kind, messageId, userId)
+			 SELECT conversationId, ?, id, ? FROM messages WHERE id = ?`
+	_, err := q.Exec(stmt, kind, userId, messageId)
+	return err
+}
+
+// recordHiddenMessageOn appends a message.deleted entry about a message that userId hid, which only they receive, to
+// the change log on q.
+func recordHiddenMessageOn(q querier, userId int64, messageId int64) error {
+	stmt := `INSERT INTO changes (conversationId, kind, messageId, userId, forUserId)
+			 SELECT conversationId, ?, id, ?, ? FROM messages WHERE id = ?`
+	_, err := q.Exec(stmt, ChangeMessageDeleted, userId, userId, messageId)
+	return err
+}
+
+// recordUserChange appends a conversation.updated entry to every conversation of a user whose profile changed.
+func (db *appdbimpl) recordUserChange(userId int64) error {
+	stmt := `INSERT INTO changes (conversationId, kind, userId)
+			 SELECT conversationId, ?, userId FROM participants WHERE userId = ? AND leftAt IS NULL`
+	_, err := db.c.Exec(stmt, ChangeConversationUpdated, userId)
+	return err
+}
+
+// GetChangesSince returns, in order, at most limit changes after afterId that are relevant to userId: those of the
+// conversations the user participates in, and the user's own removals from conversations. Changes only for other users
+// and changes to messages the user may not see are left out, unless the message is purged or the change is the user's
+// own.
+func (db *appdbimpl) GetChangesSince(userId int64, afterId int64, limit int) ([]Change, error) {
+	stmt := `SELECT c.id, c.conversationId, c.kind, c.messageId, c.userId, c.createdAt FROM changes c
+			 LEFT JOIN messages m ON m.id = c.messageId
+			 WHERE c.id > ?
+			   AND (c.conversationId IN (SELECT conversationId FROM participants WHERE userId = ? AND leftAt IS NULL)
+			        OR (c.kind = ? AND c.userId = ?))
+			   AND (c.forUserId IS NULL OR c.forUserId = ?)
+			   AND (m.id IS NULL OR c.forUserId IS NOT NULL OR ` + visibleTo + `)
+			 ORDER BY c.id
+			 LIMIT ?`
+	rows, err := db.c.Query(stmt, afterId, userId, ChangeParticipantRemoved, userId, userId, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var changes []Change
+	for rows.Next() {
+		var change Change
+		var nsMessageId sql.NullInt64
+		var nsUserId sql.NullInt64
+		if err := rows.Scan(&change.ChangeId, &change.ConversationId, &change.Kind, &nsMessageId, &nsUserId, &change.Timestamp); err != nil {
+			return nil, err
+		}
+		if nsMessageId.Valid {
+			change.MessageId = &nsMessageId.Int64
+		}
+		if nsUserId.Valid {
+			change.UserId = &nsUserId.Int64
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetLatestChangeId returns the ID of the most recent change, or 0 if the log is empty.
+func (db *appdbimpl) GetLatestChangeId() (int64, error) {
+	stmt := `SELECT COALESCE(MAX(id), 0) FROM changes`
+	var id int64
+	err := db.c.QueryRow(stmt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetOldestChangeId returns the ID of the oldest change still in the log or, if the log is empty, the ID the next
+// change will get. Changes are pruned oldest first, so a client whose cursor is before it has missed some.
+func (db *appdbimpl) GetOldestChangeId() (int64, error) {
+	stmt := `SELECT COALESCE((SELECT MIN(id) FROM changes),
+			                 (SELECT seq + 1 FROM sqlite_sequence WHERE name = 'changes'),
+			                 1)`
+	var id int64
+	err := db.c.QueryRow(stmt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// RemoveOldChanges prunes the changes older than maxAge from the log.
+func (db *appdbimpl) RemoveOldChanges(maxAge time.Duration) error {
+	stmt := `DELETE FROM changes WHERE createdAt < datetime('now', ?)`
+	_, err := db.c.Exec(stmt, fmt.Sprintf("-%d seconds", int64(maxAge.Seconds())))
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -49,15 +49,20 @@ func (db *appdbimpl) InsertParticipantsFromUsername(conversationId int64, partic
 		if err != nil {
 			return err
 		}
+		if err := db.recordChange(conversationId, ChangeParticipantAdded, nil, &userId); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func (db *appdbimpl) GetConversationsByUserId(userId int64) ([]Conversation, error) {
-	stmt := `SELECT c.id, c.name, c.isGroup, c.photoId, i.path FROM conversations c
+	stmt := `SELECT c.id, c.name, c.isGroup, c.photoId, i.path, c.messageTtl,
+			        c.renamePermission, c.photoPermission, c.addPermission, c.pinPermission, c.historyVisibility
+			 FROM conversations c
 			 JOIN participants p ON c.id = p.conversationId
 			 LEFT JOIN images AS i ON c.photoId = i.uuid
-			 WHERE p.userId = ?`
+			 WHERE p.userId = ? AND p.leftAt IS NULL`
 	rows, err := db.c.Query(stmt, userId)
 	if err != nil {
 		return nil, err
@@ -66,13 +71,21 @@ func (db *appdbimpl) GetConversationsByUserId(userId int64) ([]Conversation, err
 	var conversations []Conversation
 	var nsPhotoId sql.NullString
 	var nsPhotoPath sql.NullString
+	var nsMessageTtl sql.NullInt64
 	for rows.Next() {
 		var conv Conversation
-		err := rows.Scan(&conv.ConversationId, &conv.Name, &conv.IsGroup, &nsPhotoId, &nsPhotoPath)
+		permissions := &conv.Permissions
+		err := rows.Scan(&conv.ConversationId, &conv.Name, &conv.IsGroup, &nsPhotoId, &nsPhotoPath, &nsMessageTtl,
+			&permissions.Rename, &permissions.ChangePhoto, &permissions.AddMembers, &permissions.Pin, &conv.HistoryVisibility)
 		if err != nil {
 			return nil, err
 		}
 
+		if nsMessageTtl.Valid {
+			ttl := nsMessageTtl.Int64
+			conv.MessageTtl = &ttl
+		}
+
 		if nsPhotoId.Valid && nsPhotoPath.Valid {
 			conv.Photo = &Photo{
 				PhotoId: nsPhotoId.String,
@@ -94,7 +107,9 @@ func (db *appdbimpl) GetConversationsByUserId(userId int64) ([]Conversation, err
 }
 
 func (db *appdbimpl) GetConversationById(conversationId int64) (*Conversation, error) {
-	stmt := `SELECT c.id, c.name, c.isGroup, c.photoId, i.path FROM conversations c
+	stmt := `SELECT c.id, c.name, c.isGroup, c.photoId, i.path, c.messageTtl,
+			        c.renamePermission, c.photoPermission, c.addPermission, c.pinPermission, c.historyVisibility
+			 FROM conversations c
 			 LEFT JOIN images i ON c.photoId = i.uuid
 			 WHERE c.id = ?`
 	row := db.c.QueryRow(stmt, conversationId)
@@ -102,11 +117,18 @@ func (db *appdbimpl) GetConversationById(conversationId int64) (*Conversation, e
 	var conv Conversation
 	var nsPhotoId sql.NullString
 	var nsPhotoPath sql.NullString
-	err := row.Scan(&conv.ConversationId, &conv.Name, &conv.IsGroup, &nsPhotoId, &nsPhotoPath)
+	var nsMessageTtl sql.NullInt64
+	permissions := &conv.Permissions
+	err := row.Scan(&conv.ConversationId, &conv.Name, &conv.IsGroup, &nsPhotoId, &nsPhotoPath, &nsMessageTtl,
+		&permissions.Rename, &permissions.ChangePhoto, &permissions.AddMembers, &permissions.Pin, &conv.HistoryVisibility)
 	if err != nil {
 		return nil, err
 	}
 
+	if nsMessageTtl.Valid {
+		conv.MessageTtl = &nsMessageTtl.Int64
+	}
+
 	if nsPhotoId.Valid && nsPhotoPath.Valid {
 		conv.Photo = &Photo{
 			PhotoId: nsPhotoId.String,
@@ -123,7 +145,7 @@ func (db *appdbimpl) GetConversationById(conversationId int64) (*Conversation, e
 }
 
 func (db *appdbimpl) ParticipantExists(conversationId int64, userId int64) (bool, error) {
-	stmt := `SELECT EXISTS(SELECT 1 FROM participants WHERE conversationId = ? AND userId = ?)`
+	stmt := `SELECT EXISTS(SELECT 1 FROM participants WHERE conversationId = ? AND userId = ? AND leftAt IS NULL)`
 	var exists bool
 	err := db.c.QueryRow(stmt, conversationId, userId).Scan(&exists)
 	if err != nil {
@@ -143,12 +165,12 @@ func (db *appdbimpl) PrivateConversationExists(participants []string) (int64, er
              AND (
                SELECT COUNT(*) FROM participants p
                JOIN users u ON p.userId = u.id
-               WHERE p.conversationId = c.id
+               WHERE p.conversationId = c.id AND p.leftAt IS NULL
                AND u.username IN (?, ?)
              ) = 2
              AND (
                SELECT COUNT(*) FROM participants
-               WHERE conversationId = c.id
+               WHERE conversationId = c.id AND leftAt IS NULL
              ) = 2
              LIMIT 1`
 
@@ -162,3 +184,14 @@ func (db *appdbimpl) PrivateConversationExists(participants []string) (int64, er
 	}
 	return conversationId, nil
 }
+
+// UpdateMessageTtl sets how many seconds the new messages of a conversation last before disappearing; nil turns
+// disappearing messages off. Messages already sent keep their expiration.
+func (db *appdbimpl) UpdateMessageTtl(conversationId int64, ttlSeconds *int64) error {
+	stmt := `UPDATE conversations SET messageTtl = ? WHERE id = ?`
+	_, err := db.c.Exec(stmt, ttlSeconds, conversationId)
+	if err != nil {
+		return err
+	}
+	return db.recordChange(conversationId, ChangeConversationUpdated, nil, nil)
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (db *appdbimpl) GetCredential(userId int64) (*Credential, error) {
+	stmt := `SELECT userId, passwordHash, failedAttempts, lockedUntil,
+			 (lockedUntil IS NOT NULL AND lockedUntil > CURRENT_TIMESTAMP)
+			 FROM credentials WHERE userId = ?`
+	return scanCredential(db.c.QueryRow(stmt, userId))
+}
+
+// GetCredentialByUsername returns nil if the user does not exist or has no password.
+func (db *appdbimpl) GetCredentialByUsername(username string) (*Credential, error) {
+	stmt := `SELECT c.userId, c.passwordHash, c.failedAttempts, c.lockedUntil,
+			 (c.lockedUntil IS NOT NULL AND c.lockedUntil > CURRENT_TIMESTAMP)
+			 FROM credentials c
+			 JOIN users u ON c.userId = u.id
+			 WHERE u.username = ?`
+	return scanCredential(db.c.QueryRow(stmt, username))
+}
+
+func scanCredential(row rowScanner) (*Credential, error) {
+	var credential Credential
+	var nsLockedUntil sql.NullString
+	err := row.Scan(
+		&credential.UserId,
+		&credential.PasswordHash,
+		&credential.FailedAttempts,
+		&nsLockedUntil,
+		&credential.IsLocked,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // The account has no password
+		}
+		return nil, err
+	}
+
+	if nsLockedUntil.Valid {
+		credential.LockedUntil = &nsLockedUntil.String
+	}
+
+	return &credential, nil
+}
+
+func (db *appdbimpl) SetPassword(userId int64, passwordHash string) error {
+	stmt := `
+    INSERT INTO credentials (userId, passwordHash)
+    VALUES (?, ?)
+    ON CONFLICT(userId) DO UPDATE
+      SET passwordHash   = excluded.passwordHash,
+          failedAttempts = 0,
+          lockedUntil    = NULL,
+          updatedAt      = CURRENT_TIMESTAMP
+    `
+	_, err := db.c.Exec(stmt, userId, passwordHash)
+	return err
+}
+
+// RecordFailedLogin counts a failed password check. Once maxAttempts consecutive failures are reached the account is
+// locked for the lockout duration and the counter starts again.
+func (db *appdbimpl) RecordFailedLogin(userId int64, maxAttempts int, lockout time.Duration) error {
+	stmt := `
+    UPDATE credentials
+    SET failedAttempts = CASE WHEN failedAttempts + 1 >= ? THEN 0 ELSE failedAttempts + 1 END,
+        lockedUntil    = CASE WHEN failedAttempts + 1 >= ? THEN datetime('now', ?) ELSE lockedUntil END
+    WHERE userId = ?
+    `
+	_, err := db.c.Exec(stmt, maxAttempts, maxAttempts, fmt.Sprintf("+%d seconds", int64(lockout.Seconds())), userId)
+	return err
+}
+
+func (db *appdbimpl) ResetFailedLogins(userId int64) error {
+	stmt := `UPDATE credentials SET failedAttempts = 0, lockedUntil = NULL WHERE userId = ?`
+	_, err := db.c.Exec(stmt, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
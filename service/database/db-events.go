@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// InsertUserEvent stores an event for userId with the next number of the user's event sequence, and returns that
+// number together with the event creation time. The sequence never goes back, even when old events are removed.
+func (db *appdbimpl) InsertUserEvent(userId int64, eventType string, conversationId int64, payload *string) (int64, string, error) {
+	seqStmt := `
+    INSERT INTO event_sequences (userId, lastSeq)
+    VALUES (?, 1)
+    ON CONFLICT(userId) DO UPDATE
+      SET lastSeq = lastSeq + 1
+    RETURNING lastSeq
+    `
+	var seq int64
+	if err := db.c.QueryRow(seqStmt, userId).Scan(&seq); err != nil {
+		return 0, "", err
+	}
+
+	stmt := `INSERT INTO user_events (userId, seq, type, conversationId, payload) VALUES (?, ?, ?, ?, ?) RETURNING createdAt`
+	var createdAt string
+	if err := db.c.QueryRow(stmt, userId, seq, eventType, conversationId, payload).Scan(&createdAt); err != nil {
+		return 0, "", err
+	}
+
+	return seq, createdAt, nil
+}
+
+// GetUserEventsSince returns, in order, at most limit events of userId with a sequence number greater than afterSeq.
+func (db *appdbimpl) GetUserEventsSince(userId int64, afterSeq int64, limit int) ([]UserEvent, error) {
+	stmt := `SELECT seq, type, conversationId, payload, createdAt FROM user_events
+			 WHERE userId = ? AND seq > ?
+			 ORDER BY seq
+			 LIMIT ?`
+	rows, err := db.c.Query(stmt, userId, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var userEvents []UserEvent
+	for rows.Next() {
+		var ev UserEvent
+		var nsPayload sql.NullString
+		if err := rows.Scan(&ev.Seq, &ev.Type, &ev.ConversationId, &nsPayload, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if nsPayload.Valid {
+			ev.Payload = &nsPayload.String
+		}
+		userEvents = append(userEvents, ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return userEvents, nil
+}
+
+func (db *appdbimpl) RemoveOldUserEvents(maxAge time.Duration) error {
+	stmt := `DELETE FROM user_events WHERE createdAt < datetime('now', ?)`
+	_, err := db.c.Exec(stmt, fmt.Sprintf("-%d seconds", int64(maxAge.Seconds())))
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// nullFile scans the columns of a File that may be absent because of a LEFT JOIN.
+type nullFile struct {
+	FileId     sql.NullString
+	Path       sql.NullString
+	Filename   sql.NullString
+	Size       sql.NullInt64
+	MimeType   sql.NullString
+	UploadedBy sql.NullInt64
+	DurationMs sql.NullInt64
+	Waveform   []byte
+}
+
+func (nf nullFile) file() *File {
+	if !nf.FileId.Valid {
+		return nil
+	}
+	file := &File{
+		FileId:     nf.FileId.String,
+		Path:       nf.Path.String,
+		Filename:   nf.Filename.String,
+		Size:       nf.Size.Int64,
+		MimeType:   nf.MimeType.String,
+		UploadedBy: nf.UploadedBy.Int64,
+	}
+	if nf.DurationMs.Valid {
+		file.DurationMs = &nf.DurationMs.Int64
+		file.Waveform = nf.Waveform
+	}
+	return file
+}
+
+// InsertFile stores an uploaded file, which is a voice note if its duration is set.
+func (db *appdbimpl) InsertFile(file File) error {
+	stmt := `INSERT INTO files (uuid, path, filename, size, mimeType, uploadedBy, durationMs, waveform) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.c.Exec(stmt, file.FileId, file.Path, file.Filename, file.Size, file.MimeType, file.UploadedBy, file.DurationMs, file.Waveform)
+	return err
+}
+
+// GetFile returns an uploaded file, or nil if there is none with the given ID.
+func (db *appdbimpl) GetFile(fileId string) (*File, error) {
+	stmt := `SELECT uuid, path, filename, size, mimeType, uploadedBy, durationMs, waveform FROM files WHERE uuid = ?`
+	var nf nullFile
+	err := db.c.QueryRow(stmt, fileId).Scan(&nf.FileId, &nf.Path, &nf.Filename, &nf.Size, &nf.MimeType, &nf.UploadedBy, &nf.DurationMs, &nf.Waveform)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return nf.file(), nil
+}
+
+// CanAccessFile reports whether userId may download a file: its uploader always can, other users only if the file
+// was sent to a conversation they take part in, in a message they may see.
+func (db *appdbimpl) CanAccessFile(fileId string, userId int64) (bool, error) {
+	stmt := `SELECT EXISTS (SELECT 1 FROM files WHERE uuid = ? AND uploadedBy = ?)
+			     OR EXISTS (SELECT 1 FROM messages m
+			                JOIN participants p ON p.conversationId = m.conversationId
+			                WHERE m.fileId = ? AND p.userId = ? AND p.leftAt IS NULL AND ` + visibleTo + `)`
+	var allowed bool
+	err := db.c.QueryRow(stmt, fileId, userId, fileId, userId, userId).Scan(&allowed)
+	return allowed, err
+}
+
+// removeUnusedFile deletes a file that is no longer used by any message, and returns its path so that it can be
+// removed from disk. It returns nil if the file is still in use.
+func removeUnusedFile(q querier, fileId string) (*string, error) {
+	stmt := `DELETE FROM files WHERE uuid = ?
+			   AND NOT EXISTS (SELECT 1 FROM messages WHERE fileId = ?)
+			   AND NOT EXISTS (SELECT 1 FROM scheduled_messages WHERE fileId = ?)
+			 RETURNING path`
+	var path string
+	err := q.QueryRow(stmt, fileId, fileId, fileId).Scan(&path)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &path, nil
+}
+
+// getMessageFiles loads the files attached to the messages matching filter, a condition on the messages table
+// aliased as m, by message ID.
+func (db *appdbimpl) getMessageFiles(filter string, args ...interface{}) (map[int64]*File, error) {
+	stmt := `SELECT fm.id, f.uuid, f.path, f.filename, f.size, f.mimeType, f.uploadedBy, f.durationMs, f.waveform
+			 FROM messages fm
+			 JOIN files f ON fm.fileId = f.uuid
+			 WHERE fm.id IN (SELECT m.id FROM messages m WHERE ` + filter + `)`
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	files := make(map[int64]*File)
+	for rows.Next() {
+		var messageId int64
+		var nf nullFile
+		if err := rows.Scan(&messageId, &nf.FileId, &nf.Path, &nf.Filename, &nf.Size, &nf.MimeType, &nf.UploadedBy, &nf.DurationMs, &nf.Waveform); err != nil {
+			return nil, err
+		}
+		files[messageId] = nf.file()
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -1,12 +1,14 @@
 package database
 
+import "database/sql"
+
 func (db *appdbimpl) UpdateGroupName(conversationId int64, name string) error {
 	stmt := `UPDATE conversations SET name = ? WHERE id = ?`
 	_, err := db.c.Exec(stmt, name, conversationId)
 	if err != nil {
 		return err
 	}
-	return nil
+	return db.recordChange(conversationId, ChangeConversationUpdated, nil, nil)
 }
 
 func (db *appdbimpl) UpdateGroupPhoto(conversationId int64, photoId string) error {
@@ -15,5 +17,81 @@ func (db *appdbimpl) UpdateGroupPhoto(conversationId int64, photoId string) erro
 	if err != nil {
 		return err
 	}
-	return nil
+	return db.recordChange(conversationId, ChangeConversationUpdated, nil, nil)
+}
+
+// GetParticipantRole returns the role of userId in a conversation, or an empty string if they do not take part in it.
+func (db *appdbimpl) GetParticipantRole(conversationId int64, userId int64) (string, error) {
+	stmt := `SELECT role FROM participants WHERE conversationId = ? AND userId = ? AND leftAt IS NULL`
+	var role string
+	err := db.c.QueryRow(stmt, conversationId, userId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// SetParticipantRole changes the role of userId in a conversation. It returns false if they do not take part in it.
+func (db *appdbimpl) SetParticipantRole(conversationId int64, userId int64, role string) (bool, error) {
+	stmt := `UPDATE participants SET role = ? WHERE conversationId = ? AND userId = ? AND leftAt IS NULL`
+	result, err := db.c.Exec(stmt, role, conversationId, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	return true, db.recordChange(conversationId, ChangeConversationUpdated, nil, &userId)
+}
+
+// EnsureOwner gives a group whose owner left a new one: its longest-standing admin, or else its longest-standing
+// member. It returns the new owner, or nil if the group still has an owner or no participants are left.
+func (db *appdbimpl) EnsureOwner(conversationId int64) (*int64, error) {
+	stmt := `SELECT p.userId FROM participants p
+			 JOIN conversations c ON c.id = p.conversationId
+			 WHERE p.conversationId = ? AND c.isGroup AND p.leftAt IS NULL
+			   AND NOT EXISTS (SELECT 1 FROM participants o WHERE o.conversationId = p.conversationId AND o.role = ? AND o.leftAt IS NULL)
+			 ORDER BY p.role = ? DESC, p.joinedAt, p.rowid
+			 LIMIT 1`
+	var userId int64
+	err := db.c.QueryRow(stmt, conversationId, RoleOwner, RoleAdmin).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.SetParticipantRole(conversationId, userId, RoleOwner); err != nil {
+		return nil, err
+	}
+	return &userId, nil
+}
+
+// GetGroupPermissions returns who may perform each of the restricted actions in a conversation.
+func (db *appdbimpl) GetGroupPermissions(conversationId int64) (GroupPermissions, error) {
+	stmt := `SELECT renamePermission, photoPermission, addPermission, pinPermission FROM conversations WHERE id = ?`
+	var permissions GroupPermissions
+	err := db.c.QueryRow(stmt, conversationId).Scan(&permissions.Rename, &permissions.ChangePhoto, &permissions.AddMembers, &permissions.Pin)
+	return permissions, err
+}
+
+func (db *appdbimpl) UpdateGroupPermissions(conversationId int64, permissions GroupPermissions) error {
+	stmt := `UPDATE conversations SET renamePermission = ?, photoPermission = ?, addPermission = ?, pinPermission = ? WHERE id = ?`
+	_, err := db.c.Exec(stmt, permissions.Rename, permissions.ChangePhoto, permissions.AddMembers, permissions.Pin, conversationId)
+	if err != nil {
+		return err
+	}
+	return db.recordChange(conversationId, ChangeConversationUpdated, nil, nil)
+}
+
+// UpdateHistoryVisibility sets which messages the participants of a group see, HistoryFull or HistorySinceJoining. The
+// setting applies to the messages already sent as well.
+func (db *appdbimpl) UpdateHistoryVisibility(conversationId int64, setting string) error {
+	stmt := `UPDATE conversations SET historyVisibility = ? WHERE id = ?`
+	_, err := db.c.Exec(stmt, setting, conversationId)
+	if err != nil {
+		return err
+	}
+	return db.recordChange(conversationId, ChangeConversationUpdated, nil, nil)
 }
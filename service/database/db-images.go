@@ -1,5 +1,7 @@
 package database
 
+import "database/sql"
+
 func (db *appdbimpl) InsertImage(uuid string, path string) error {
 	stmt := `INSERT INTO images (uuid, path) VALUES (?, ?)`
 	_, err := db.c.Exec(stmt, uuid, path)
@@ -18,3 +20,22 @@ func (db *appdbimpl) GetImagePath(uuid string) (string, error) {
 	}
 	return path, nil
 }
+
+// removeUnusedImage deletes an image that is no longer used by any message, user or conversation, and returns its path
+// so that the file can be removed. It returns nil if the image is still in use.
+func removeUnusedImage(q querier, uuid string) (*string, error) {
+	stmt := `DELETE FROM images WHERE uuid = ?
+			   AND NOT EXISTS (SELECT 1 FROM messages WHERE photoId = ?)
+			   AND NOT EXISTS (SELECT 1 FROM scheduled_messages WHERE photoId = ?)
+			   AND NOT EXISTS (SELECT 1 FROM users WHERE photoId = ?)
+			   AND NOT EXISTS (SELECT 1 FROM conversations WHERE photoId = ?)
+			 RETURNING path`
+	var path string
+	err := q.QueryRow(stmt, uuid, uuid, uuid, uuid, uuid).Scan(&path)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &path, nil
+}
@@ -1,18 +1,70 @@
 package database
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type MessageDatabase interface {
-	InsertMessage(conversationId int64, userId int64, content *string, photoId *string, replyTo *int64, isForwarded bool) (int64, string, error)
+	InsertMessage(conversationId int64, userId int64, content *string, photoId *string, fileId *string, replyTo *int64, threadRootId *int64, inConversation bool, isForwarded bool) (int64, string, error)
+	InsertVoiceMessage(conversationId int64, userId int64, fileId string, replyTo *int64, threadRootId *int64, inConversation bool) (int64, error)
+	InsertSystemMessage(conversationId int64, actorId int64, kind string, data SystemData) (int64, error)
 	RemoveMessage(messageId int64) error
+	RemoveExpiredMessages(limit int) ([]ExpiredMessage, []string, error)
+	HideMessage(userId int64, messageId int64) error
+	EditMessage(messageId int64, content string) (string, error)
+	GetMessageRevisions(messageId int64) ([]MessageRevision, error)
 	GetSenderId(messageId int64) (int64, error)
-	GetChat(conversationID int64) ([]MessageView, error)
+	GetChatPage(conversationId int64, viewerId int64, beforeId *int64, afterId *int64, limit int) ([]MessageView, bool, error)
+	GetThreadPage(rootId int64, viewerId int64, beforeId *int64, afterId *int64, limit int) ([]MessageView, bool, error)
+	GetMessage(conversationId int64, messageId int64) (*MessageView, error)
+	GetVisibleMessage(conversationId int64, viewerId int64, messageId int64) (*MessageView, error)
+	GetMessagesByIds(messageIds []int64) ([]MessageView, error)
+	GetVisibleMessagesByIds(viewerId int64, messageIds []int64) ([]MessageView, error)
+	GetMessageContext(conversationId int64, viewerId int64, messageId int64, radius int) ([]MessageView, bool, bool, error)
 	GetConversationIdFromMessageId(messageId int64) (int64, error)
 	ForwardMessage(messageIdToForward int64, conversationId int64, forwarderId int64) (messageId int64, timestamp string, content *string, photoId *string, err error)
-	GetLastMessage(conversationId int64) (*MessageView, error)
+	GetLastMessage(conversationId int64, viewerId int64) (*MessageView, error)
 	IsConversationEmpty(conversationId int64) (bool, error)
 }
 
+type ScheduledMessageDatabase interface {
+	InsertScheduledMessage(conversationId int64, senderId int64, content *string, photoId *string, fileId *string, replyTo *int64, threadRootId *int64, inConversation bool, sendAt time.Time) (int64, error)
+	GetScheduledMessage(scheduledId int64, senderId int64) (*ScheduledMessage, error)
+	GetScheduledMessages(senderId int64) ([]ScheduledMessage, error)
+	GetDueScheduledMessages(limit int) ([]ScheduledMessage, error)
+	UpdateScheduledMessageTime(scheduledId int64, senderId int64, sendAt time.Time) (bool, error)
+	SendScheduledMessage(scheduledId int64) (messageId int64, sent bool, err error)
+	RemoveUserScheduledMessage(scheduledId int64, senderId int64) (bool, error)
+}
+
+type PinDatabase interface {
+	InsertPin(conversationId int64, messageId int64, pinnedBy int64, maxPins int) (bool, error)
+	RemovePin(conversationId int64, messageId int64, userId int64) (bool, error)
+	GetPins(conversationId int64, viewerId int64) ([]Pin, error)
+}
+
+type PollDatabase interface {
+	InsertPoll(conversationId int64, userId int64, poll NewPoll, replyTo *int64, threadRootId *int64, inConversation bool) (int64, error)
+	SetPollVotes(messageId int64, userId int64, positions []int) error
+	GetPollVotes(messageId int64, userId int64) ([]int, error)
+}
+
+type StarDatabase interface {
+	InsertStar(userId int64, messageId int64) error
+	RemoveStar(userId int64, messageId int64) (bool, error)
+	GetStarredPage(userId int64, beforeId *int64, limit int) ([]MessageView, bool, error)
+}
+
+type MentionDatabase interface {
+	SetMentions(messageId int64, mentions []Mention) error
+	GetMentionsPage(userId int64, beforeId *int64, limit int) ([]MessageView, bool, error)
+}
+
+type SearchDatabase interface {
+	SearchMessages(viewerId int64, text string, filter SearchFilter, offset int, limit int) ([]SearchHit, bool, error)
+}
+
 type ReactionDatabase interface {
 	InsertReaction(messageId int64, userId int64, reaction string) error
 	RemoveReaction(messageId int64, userId int64) error
@@ -20,7 +72,7 @@ type ReactionDatabase interface {
 }
 
 type ParticipantDatabase interface {
-	InsertParticipants(conversationId int64, userId []int64) error
+	InsertParticipants(conversationId int64, userId []int64) ([]int64, error)
 	RemoveParticipant(conversationId int64, userId int64) error
 	GetParticipants(conversationId int64) ([]User, error)
 	GetParticipantIds(conversationId int64) ([]int64, error)
@@ -28,19 +80,43 @@ type ParticipantDatabase interface {
 
 type StatusDatabase interface {
 	InsertSent(messageId int64, conversationId int64, recipientIds []int64) error
-	InsertDelivered(recipientId int64) error
-	InsertRead(conversationId int64, recipientId int64) error
+	InsertDelivered(recipientId int64) ([]int64, error)
+	InsertRead(conversationId int64, recipientId int64) (bool, error)
+	CountUnread(conversationId int64, recipientId int64) (int64, error)
 }
 
 type GroupDatabase interface {
 	UpdateGroupName(conversationId int64, name string) error
 	UpdateGroupPhoto(conversationId int64, photoId string) error
+	GetParticipantRole(conversationId int64, userId int64) (string, error)
+	SetParticipantRole(conversationId int64, userId int64, role string) (bool, error)
+	EnsureOwner(conversationId int64) (*int64, error)
+	GetGroupPermissions(conversationId int64) (GroupPermissions, error)
+	UpdateGroupPermissions(conversationId int64, permissions GroupPermissions) error
+	UpdateHistoryVisibility(conversationId int64, setting string) error
+	InsertBan(conversationId int64, userId int64, bannedBy int64) error
+	RemoveBan(conversationId int64, userId int64) (bool, error)
+	GetBans(conversationId int64) ([]Ban, error)
+	IsBanned(conversationId int64, userId int64) (bool, error)
+}
+
+type InviteDatabase interface {
+	InsertInvite(code string, conversationId int64, createdBy int64, invite NewInvite) error
+	GetInvite(code string) (*Invite, error)
+	GetInvites(conversationId int64) ([]Invite, error)
+	RemoveInvite(conversationId int64, code string) (bool, error)
+	UseInvite(code string) (bool, error)
+	InsertJoinRequest(conversationId int64, userId int64, inviteCode string) error
+	GetJoinRequest(conversationId int64, userId int64) (*JoinRequest, error)
+	GetJoinRequests(conversationId int64) ([]JoinRequest, error)
+	RemoveJoinRequest(conversationId int64, userId int64) (bool, error)
 }
 
 type ConversationDatabase interface {
 	InsertConversation(name string, participants []string, isGroup bool, photo *string) (int64, error)
 	GetConversationsByUserId(userId int64) ([]Conversation, error)
 	GetConversationById(conversationId int64) (*Conversation, error)
+	UpdateMessageTtl(conversationId int64, ttlSeconds *int64) error
 	ParticipantExists(conversationId int64, userId int64) (bool, error)
 	PrivateConversationExists(participants []string) (int64, error)
 }
@@ -59,24 +135,85 @@ type UserDatabase interface {
 	GetAllUsers() ([]User, error)
 }
 
+type SessionDatabase interface {
+	InsertSession(userId int64, token string, ttl time.Duration, deviceLabel *string, userAgent string, ipAddress string) (int64, error)
+	GetSessionByToken(token string) (*Session, error)
+	GetSessions(userId int64) ([]Session, error)
+	TouchSession(sessionId int64) error
+	RemoveSession(sessionId int64) error
+	RemoveUserSession(sessionId int64, userId int64) (bool, error)
+	RemoveAllSessions(userId int64) error
+	RemoveOtherSessions(userId int64, keepSessionId int64) error
+	RemoveExpiredSessions() error
+}
+
+type CredentialDatabase interface {
+	GetCredential(userId int64) (*Credential, error)
+	GetCredentialByUsername(username string) (*Credential, error)
+	SetPassword(userId int64, passwordHash string) error
+	RecordFailedLogin(userId int64, maxAttempts int, lockout time.Duration) error
+	ResetFailedLogins(userId int64) error
+}
+
+type EventDatabase interface {
+	InsertUserEvent(userId int64, eventType string, conversationId int64, payload *string) (int64, string, error)
+	GetUserEventsSince(userId int64, afterSeq int64, limit int) ([]UserEvent, error)
+	RemoveOldUserEvents(maxAge time.Duration) error
+}
+
+type ChangeDatabase interface {
+	GetChangesSince(userId int64, afterId int64, limit int) ([]Change, error)
+	GetLatestChangeId() (int64, error)
+	GetOldestChangeId() (int64, error)
+	RemoveOldChanges(maxAge time.Duration) error
+}
+
 type ImageDatabase interface {
 	InsertImage(uuid string, path string) error
 	GetImagePath(uuid string) (string, error)
 }
 
+type FileDatabase interface {
+	InsertFile(file File) error
+	GetFile(fileId string) (*File, error)
+	CanAccessFile(fileId string, userId int64) (bool, error)
+}
+
 // AppDatabase is the interface through which all DB operations are performed.
 type AppDatabase interface {
 	UserDatabase
+	SessionDatabase
+	CredentialDatabase
 	ImageDatabase
+	FileDatabase
 	ConversationDatabase
 	ParticipantDatabase
 	GroupDatabase
+	InviteDatabase
 	MessageDatabase
+	ScheduledMessageDatabase
+	PinDatabase
+	PollDatabase
+	StarDatabase
+	MentionDatabase
+	SearchDatabase
 	ReactionDatabase
 	StatusDatabase
+	EventDatabase
+	ChangeDatabase
 	Ping() error
 }
 
 type appdbimpl struct {
 	c *sql.DB
+
+	// fts reports whether messages are searched with the full-text index, rather than by substring
+	fts bool
+}
+
+// querier runs statements either directly on the database or within a transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// inviteUsable is the condition under which an invite link can still be used.
+const inviteUsable = `(expiresAt IS NULL OR expiresAt > CURRENT_TIMESTAMP) AND (maxUses IS NULL OR uses < maxUses)`
+
+// InsertInvite stores a new invite link to a group, created by createdBy.
+func (db *appdbimpl) InsertInvite(code string, conversationId int64, createdBy int64, invite NewInvite) error {
+	var expiresAt *string
+	if invite.ExpiresAt != nil {
+		formatted := invite.ExpiresAt.UTC().Format(sqliteTimeLayout)
+		expiresAt = &formatted
+	}
+	stmt := `INSERT INTO invites (code, conversationId, createdBy, expiresAt, maxUses, requiresApproval) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := db.c.Exec(stmt, code, conversationId, createdBy, expiresAt, invite.MaxUses, invite.RequiresApproval)
+	return err
+}
+
+// selectInvites selects the columns read by scanInvite.
+const selectInvites = `SELECT iv.code, iv.conversationId, strftime('%Y-%m-%dT%H:%M:%SZ', iv.createdAt),
+		strftime('%Y-%m-%dT%H:%M:%SZ', iv.expiresAt), iv.maxUses, iv.uses, iv.requiresApproval,
+		u.id, u.username, u.photoId, i.path
+	FROM invites iv
+	JOIN users u ON iv.createdBy = u.id
+	LEFT JOIN images i ON u.photoId = i.uuid`
+
+func scanInvite(row rowScanner) (*Invite, error) {
+	var invite Invite
+	var nsExpiresAt, nsPhotoId, nsPhotoPath sql.NullString
+	var niMaxUses sql.NullInt64
+	err := row.Scan(&invite.Code, &invite.ConversationId, &invite.CreatedAt, &nsExpiresAt, &niMaxUses, &invite.Uses,
+		&invite.RequiresApproval, &invite.CreatedBy.UserId, &invite.CreatedBy.Username, &nsPhotoId, &nsPhotoPath)
+	if err != nil {
+		return nil, err
+	}
+	if nsExpiresAt.Valid {
+		invite.ExpiresAt = &nsExpiresAt.String
+	}
+	if niMaxUses.Valid {
+		invite.MaxUses = &niMaxUses.Int64
+	}
+	if nsPhotoId.Valid && nsPhotoPath.Valid {
+		invite.CreatedBy.Photo = &Photo{PhotoId: nsPhotoId.String, Path: nsPhotoPath.String}
+	}
+	return &invite, nil
+}
+
+// GetInvite returns the invite link with the given code, or nil if there is none. The link may have expired or run
+// out of uses.
+func (db *appdbimpl) GetInvite(code string) (*Invite, error) {
+	invite, err := scanInvite(db.c.QueryRow(selectInvites+` WHERE iv.code = ?`, code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return invite, err
+}
+
+// GetInvites returns the invite links of a group, including the ones that can no longer be used, the most recent
+// first.
+func (db *appdbimpl) GetInvites(conversationId int64) ([]Invite, error) {
+	rows, err := db.c.Query(selectInvites+` WHERE iv.conversationId = ? ORDER BY iv.createdAt DESC, iv.rowid DESC`, conversationId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var invites []Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *invite)
+	}
+	return invites, rows.Err()
+}
+
+// RemoveInvite revokes an invite link of a group, and reports whether it existed. Pending join requests made through
+// it are kept.
+func (db *appdbimpl) RemoveInvite(conversationId int64, code string) (bool, error) {
+	result, err := db.c.Exec(`DELETE FROM invites WHERE conversationId = ? AND code = ?`, conversationId, code)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UseInvite counts a use of an invite link, and reports whether it could still be used. The check is made by the
+// update itself, so that concurrent uses cannot exceed the maximum.
+func (db *appdbimpl) UseInvite(code string) (bool, error) {
+	result, err := db.c.Exec(`UPDATE invites SET uses = uses + 1 WHERE code = ? AND `+inviteUsable, code)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// InsertJoinRequest records that userId asked to join a group through the invite link inviteCode. Asking again has
+// no effect.
+func (db *appdbimpl) InsertJoinRequest(conversationId int64, userId int64, inviteCode string) error {
+	stmt := `INSERT INTO join_requests (conversationId, userId, inviteCode) VALUES (?, ?, ?)
+			 ON CONFLICT (conversationId, userId) DO NOTHING`
+	_, err := db.c.Exec(stmt, conversationId, userId, inviteCode)
+	return err
+}
+
+// selectJoinRequests selects the columns read by scanJoinRequest.
+const selectJoinRequests = `SELECT r.inviteCode, strftime('%Y-%m-%dT%H:%M:%SZ', r.requestedAt), u.id, u.username, u.photoId, i.path
+	FROM join_requests r
+	JOIN users u ON r.userId = u.id
+	LEFT JOIN images i ON u.photoId = i.uuid`
+
+func scanJoinRequest(row rowScanner) (*JoinRequest, error) {
+	var request JoinRequest
+	var nsPhotoId, nsPhotoPath sql.NullString
+	err := row.Scan(&request.InviteCode, &request.RequestedAt, &request.User.UserId, &request.User.Username, &nsPhotoId, &nsPhotoPath)
+	if err != nil {
+		return nil, err
+	}
+	if nsPhotoId.Valid && nsPhotoPath.Valid {
+		request.User.Photo = &Photo{PhotoId: nsPhotoId.String, Path: nsPhotoPath.String}
+	}
+	return &request, nil
+}
+
+// GetJoinRequest returns the pending request of userId to join a group, or nil if there is none.
+func (db *appdbimpl) GetJoinRequest(conversationId int64, userId int64) (*JoinRequest, error) {
+	request, err := scanJoinRequest(db.c.QueryRow(selectJoinRequests+` WHERE r.conversationId = ? AND r.userId = ?`, conversationId, userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return request, err
+}
+
+// GetJoinRequests returns the pending requests to join a group, the oldest first.
+func (db *appdbimpl) GetJoinRequests(conversationId int64) ([]JoinRequest, error) {
+	rows, err := db.c.Query(selectJoinRequests+` WHERE r.conversationId = ? ORDER BY r.requestedAt, r.rowid`, conversationId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var requests []JoinRequest
+	for rows.Next() {
+		request, err := scanJoinRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	return requests, rows.Err()
+}
+
+// RemoveJoinRequest deletes the pending request of userId to join a group, once approved or denied, and reports
+// whether there was one.
+func (db *appdbimpl) RemoveJoinRequest(conversationId int64, userId int64) (bool, error) {
+	result, err := db.c.Exec(`DELETE FROM join_requests WHERE conversationId = ? AND userId = ?`, conversationId, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
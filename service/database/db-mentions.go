@@ -0,0 +1,70 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// SetMentions replaces the users mentioned in a message. Mentions refer to users by ID, so that they survive renames.
+func (db *appdbimpl) SetMentions(messageId int64, mentions []Mention) error {
+	if _, err := db.c.Exec(`DELETE FROM mentions WHERE messageId = ?`, messageId); err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO mentions (messageId, userId, position, length) VALUES (?, ?, ?, ?)`
+	for _, mention := range mentions {
+		if _, err := db.c.Exec(stmt, messageId, mention.User.UserId, mention.Position, mention.Length); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMentionsPage returns, like GetChatPage, a page of the messages mentioning userId in the conversations the user
+// still takes part in, leaving out the user's own messages.
+func (db *appdbimpl) GetMentionsPage(userId int64, beforeId *int64, limit int) ([]MessageView, bool, error) {
+	const scope = `m.id IN (SELECT mn.messageId FROM mentions mn WHERE mn.userId = ?)
+		AND m.conversationId IN (SELECT p.conversationId FROM participants p WHERE p.userId = ? AND p.leftAt IS NULL)
+		AND m.senderId <> ?`
+	return db.getMessagePage(scope, []interface{}{userId, userId, userId}, userId, beforeId, nil, limit)
+}
+
+// getMentions loads the mentions of the messages matching filter, a condition on the messages table aliased as m,
+// by message ID and in order of appearance.
+func (db *appdbimpl) getMentions(filter string, args ...interface{}) (map[int64][]Mention, error) {
+	stmt := `SELECT mn.messageId, mn.position, mn.length, u.id, u.username, u.photoId, i.path
+			 FROM mentions mn
+			 JOIN users u ON mn.userId = u.id
+			 LEFT JOIN images i ON u.photoId = i.uuid
+			 WHERE mn.messageId IN (SELECT m.id FROM messages m WHERE ` + filter + `)
+			 ORDER BY mn.messageId, mn.position`
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	mentions := make(map[int64][]Mention)
+	for rows.Next() {
+		var messageId int64
+		var mention Mention
+		var nsPhotoId sql.NullString
+		var nsPhotoPath sql.NullString
+		if err := rows.Scan(&messageId, &mention.Position, &mention.Length, &mention.User.UserId, &mention.User.Username, &nsPhotoId, &nsPhotoPath); err != nil {
+			return nil, err
+		}
+		if nsPhotoId.Valid && nsPhotoPath.Valid {
+			mention.User.Photo = &Photo{
+				PhotoId: nsPhotoId.String,
+				Path:    nsPhotoPath.String,
+			}
+		}
+		mentions[messageId] = append(mentions[messageId], mention)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
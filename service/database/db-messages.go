@@ -2,24 +2,234 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"math"
 	"sort"
+	"strings"
 
 	"github.com/Reewd/WASAproject/service/database/helpers"
 )
 
-func (db *appdbimpl) InsertMessage(conversationId int64, userId int64, content *string, photoId *string, replyTo *int64, isForwarded bool) (int64, string, error) {
-	stmt := `INSERT into messages (conversationId, senderId, content, photoId, replyTo, isForwarded) VALUES (?, ?, ?, ?, ?, ?) RETURNING id, timestamp`
+// messageExpiry computes the expiration time of a message created now in the conversation given as argument, which is
+// NULL unless disappearing messages are enabled there.
+const messageExpiry = `(SELECT datetime('now', '+' || messageTtl || ' seconds') FROM conversations WHERE id = ?)`
+
+// InsertMessage stores a new message. A message posted in the thread of threadRootId only appears in the conversation
+// itself if inConversation is set, which it must be for messages outside of threads.
+func (db *appdbimpl) InsertMessage(conversationId int64, userId int64, content *string, photoId *string, fileId *string, replyTo *int64, threadRootId *int64, inConversation bool, isForwarded bool) (int64, string, error) {
+	return insertMessage(db.c, conversationId, userId, KindMessage, content, photoId, fileId, replyTo, threadRootId, inConversation, isForwarded)
+}
+
+// InsertVoiceMessage stores a new message of kind KindVoice, which has no text and plays the voice note fileId. Like
+// InsertMessage, a voice message posted in a thread only appears in the conversation if inConversation is set.
+func (db *appdbimpl) InsertVoiceMessage(conversationId int64, userId int64, fileId string, replyTo *int64, threadRootId *int64, inConversation bool) (int64, error) {
+	messageId, _, err := insertMessage(db.c, conversationId, userId, KindVoice, nil, nil, &fileId, replyTo, threadRootId, inConversation, false)
+	return messageId, err
+}
+
+// insertMessage stores on q a new message sent by a user, of kind KindMessage, KindPoll or KindVoice.
+func insertMessage(q querier, conversationId int64, userId int64, kind string, content *string, photoId *string, fileId *string, replyTo *int64, threadRootId *int64, inConversation bool, isForwarded bool) (int64, string, error) {
+	stmt := `INSERT into messages (conversationId, senderId, kind, content, photoId, fileId, replyTo, threadRootId, inConversation, isForwarded, expiresAt)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + messageExpiry + `) RETURNING id, timestamp`
 	var timestamp string
 	var messageId int64
 
-	err := db.c.QueryRow(stmt, conversationId, userId, content, photoId, replyTo, isForwarded).Scan(&messageId, &timestamp)
+	err := q.QueryRow(stmt, conversationId, userId, kind, content, photoId, fileId, replyTo, threadRootId, inConversation, isForwarded, conversationId).Scan(&messageId, &timestamp)
 	if err != nil {
 		return 0, "", err
 	}
 
+	if err := recordChangeOn(q, conversationId, ChangeMessageCreated, &messageId, &userId); err != nil {
+		return 0, "", err
+	}
+	if threadRootId != nil {
+		if err := recordChangeOn(q, conversationId, ChangeThreadUpdated, threadRootId, &userId); err != nil {
+			return 0, "", err
+		}
+	}
+
 	return messageId, timestamp, nil
 }
 
+// InsertSystemMessage records in a conversation a change made by actorId, e.g. to its settings. System messages have
+// a kind other than KindMessage, no text, and the kind-specific details in data.
+func (db *appdbimpl) InsertSystemMessage(conversationId int64, actorId int64, kind string, data SystemData) (int64, error) {
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+
+	// System messages never expire, so that the history of the conversation settings stays visible
+	stmt := `INSERT INTO messages (conversationId, senderId, kind, systemData) VALUES (?, ?, ?, ?) RETURNING id`
+	var messageId int64
+	err = db.c.QueryRow(stmt, conversationId, actorId, kind, string(encodedData)).Scan(&messageId)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := db.recordChange(conversationId, ChangeMessageCreated, &messageId, &actorId); err != nil {
+		return 0, err
+	}
+
+	return messageId, nil
+}
+
+// RemoveExpiredMessages purges at most limit disappearing messages whose time has passed, together with the images
+// and files only they used. It returns the purged messages and the paths of the purged images and files, which the
+// caller removes from disk. The batch is purged in one transaction, so a failure leaves no message half removed.
+func (db *appdbimpl) RemoveExpiredMessages(limit int) ([]ExpiredMessage, []string, error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `SELECT id, conversationId, photoId, fileId, threadRootId FROM messages WHERE expiresAt <= CURRENT_TIMESTAMP ORDER BY expiresAt LIMIT ?`
+	rows, err := tx.Query(stmt, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var expired []ExpiredMessage
+	var photoIds []string
+	var fileIds []string
+	var threadRootIds []int64
+	for rows.Next() {
+		var em ExpiredMessage
+		var nsPhotoId sql.NullString
+		var nsFileId sql.NullString
+		var nsThreadRootId sql.NullInt64
+		if err := rows.Scan(&em.MessageId, &em.ConversationId, &nsPhotoId, &nsFileId, &nsThreadRootId); err != nil {
+			return nil, nil, err
+		}
+		expired = append(expired, em)
+		if nsPhotoId.Valid {
+			photoIds = append(photoIds, nsPhotoId.String)
+		}
+		if nsFileId.Valid {
+			fileIds = append(fileIds, nsFileId.String)
+		}
+		if nsThreadRootId.Valid {
+			threadRootIds = append(threadRootIds, nsThreadRootId.Int64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Rows referring to the messages are removed explicitly rather than relying on foreign key cascades, which are
+	// only enforced on the connection that applied the schema
+	dependents := []string{
+		`DELETE FROM reactions WHERE messageId = ?`,
+		`DELETE FROM message_status WHERE messageId = ?`,
+		`DELETE FROM message_revisions WHERE messageId = ?`,
+		`DELETE FROM hidden_messages WHERE messageId = ?`,
+		`DELETE FROM mentions WHERE messageId = ?`,
+		`DELETE FROM pins WHERE messageId = ?`,
+		`DELETE FROM starred_messages WHERE messageId = ?`,
+		`DELETE FROM poll_votes WHERE messageId = ?`,
+		`DELETE FROM poll_options WHERE messageId = ?`,
+		`DELETE FROM polls WHERE messageId = ?`,
+		`UPDATE messages SET replyTo = NULL WHERE replyTo = ?`,
+		`UPDATE scheduled_messages SET replyTo = NULL WHERE replyTo = ?`,
+		// The replies of a purged thread root fall back into the conversation
+		`UPDATE messages SET threadRootId = NULL, inConversation = TRUE WHERE threadRootId = ?`,
+		`UPDATE scheduled_messages SET threadRootId = NULL, inConversation = TRUE WHERE threadRootId = ?`,
+		`DELETE FROM messages WHERE id = ?`,
+	}
+	for _, em := range expired {
+		for _, stmt := range dependents {
+			if _, err := tx.Exec(stmt, em.MessageId); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := recordChangeOn(tx, em.ConversationId, ChangeMessageDeleted, &em.MessageId, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, rootId := range threadRootIds {
+		if err := recordMessageChangeOn(tx, ChangeThreadUpdated, rootId, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var paths []string
+	for _, photoId := range photoIds {
+		path, err := removeUnusedImage(tx, photoId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if path != nil {
+			paths = append(paths, *path)
+		}
+	}
+	for _, fileId := range fileIds {
+		path, err := removeUnusedFile(tx, fileId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if path != nil {
+			paths = append(paths, *path)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return expired, paths, nil
+}
+
+// EditMessage replaces the text of a message, keeping the previous version as a revision, and returns the time of the
+// edit.
+func (db *appdbimpl) EditMessage(messageId int64, content string) (string, error) {
+	stmt := `INSERT INTO message_revisions (messageId, content, timestamp)
+			 SELECT id, content, COALESCE(editedAt, timestamp) FROM messages WHERE id = ?`
+	if _, err := db.c.Exec(stmt, messageId); err != nil {
+		return "", err
+	}
+
+	stmt = `UPDATE messages SET content = ?, editedAt = CURRENT_TIMESTAMP WHERE id = ? RETURNING editedAt`
+	var editedAt string
+	if err := db.c.QueryRow(stmt, content, messageId).Scan(&editedAt); err != nil {
+		return "", err
+	}
+
+	if err := db.recordMessageChange(ChangeMessageEdited, messageId, nil); err != nil {
+		return "", err
+	}
+
+	return editedAt, nil
+}
+
+// GetMessageRevisions returns the previous versions of a message, oldest first.
+func (db *appdbimpl) GetMessageRevisions(messageId int64) ([]MessageRevision, error) {
+	stmt := `SELECT content, timestamp FROM message_revisions WHERE messageId = ? ORDER BY id`
+	rows, err := db.c.Query(stmt, messageId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var revisions []MessageRevision
+	for rows.Next() {
+		var revision MessageRevision
+		var nsContent sql.NullString
+		if err := rows.Scan(&nsContent, &revision.Timestamp); err != nil {
+			return nil, err
+		}
+		if nsContent.Valid {
+			revision.Text = &nsContent.String
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
 func (db *appdbimpl) GetSenderId(messageId int64) (int64, error) {
 	stmt := `SELECT senderId FROM messages WHERE id = ?`
 	var senderId int64
@@ -30,17 +240,251 @@ func (db *appdbimpl) GetSenderId(messageId int64) (int64, error) {
 	return senderId, nil
 }
 
+// RemoveMessage deletes a message for everyone, unpinning and unstarring it. Its text, photo, file, poll, reactions,
+// revisions and mentions are removed, but the row is kept as a tombstone with its sender and timestamp, so that replies
+// still point to it. All of it happens in one transaction, so a failure leaves the message as it was.
 func (db *appdbimpl) RemoveMessage(messageId int64) error {
-	stmt := `DELETE FROM messages WHERE id = ?`
-	_, err := db.c.Exec(stmt, messageId)
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `UPDATE messages SET content = NULL, photoId = NULL, fileId = NULL, deletedAt = CURRENT_TIMESTAMP
+			 WHERE id = ? AND deletedAt IS NULL
+			 RETURNING conversationId, threadRootId`
+	var conversationId int64
+	var nsThreadRootId sql.NullInt64
+	err = tx.QueryRow(stmt, messageId).Scan(&conversationId, &nsThreadRootId)
+	if err == sql.ErrNoRows {
+		return nil // Already deleted
+	} else if err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM reactions WHERE messageId = ?`,
+		`DELETE FROM message_revisions WHERE messageId = ?`,
+		`DELETE FROM mentions WHERE messageId = ?`,
+		`DELETE FROM pins WHERE messageId = ?`,
+		`DELETE FROM starred_messages WHERE messageId = ?`,
+	} {
+		if _, err := tx.Exec(stmt, messageId); err != nil {
+			return err
+		}
+	}
+	if err := removePoll(tx, messageId); err != nil {
+		return err
+	}
+
+	// Deleted replies no longer count in their thread
+	if nsThreadRootId.Valid {
+		if err := recordChangeOn(tx, conversationId, ChangeThreadUpdated, &nsThreadRootId.Int64, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := recordChangeOn(tx, conversationId, ChangeMessageDeleted, &messageId, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// HideMessage deletes a message only for userId: it no longer appears in the messages listed for that user, and their
+// other clients learn it from the change log.
+func (db *appdbimpl) HideMessage(userId int64, messageId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `INSERT OR IGNORE INTO hidden_messages (userId, messageId) VALUES (?, ?)`
+	result, err := tx.Exec(stmt, userId, messageId)
 	if err != nil {
 		return err
 	}
-	return nil
+	if hidden, err := result.RowsAffected(); err != nil {
+		return err
+	} else if hidden > 0 {
+		if err := recordHiddenMessageOn(tx, userId, messageId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// visibleTo is a filter on the messages table aliased as m that keeps the messages a user, given as its argument, may
+// see: it excludes the ones the user hid, the disappearing messages whose time has passed but that are not purged yet
+// and, in groups that only show new members the messages sent after they joined, the ones sent before the user joined.
+const visibleTo = `(m.expiresAt IS NULL OR m.expiresAt > CURRENT_TIMESTAMP) AND NOT EXISTS (SELECT 1 FROM (SELECT ? AS userId) v
+	WHERE EXISTS (SELECT 1 FROM hidden_messages h WHERE h.messageId = m.id AND h.userId = v.userId)
+	   OR EXISTS (SELECT 1 FROM participants vp
+	              JOIN conversations vc ON vc.id = vp.conversationId
+	              WHERE vp.conversationId = m.conversationId AND vp.userId = v.userId
+	                AND vc.historyVisibility = 'joined' AND m.timestamp < vp.joinedAt))`
+
+// GetChatPage returns, in chronological order, at most limit messages of a conversation as seen by viewerId, and
+// whether more messages exist past them. Without a cursor these are the latest messages; with beforeId the ones sent
+// right before that message, and with afterId the ones sent right after it. Message IDs grow with the time of sending,
+// so they are used as the pagination key and the cursor message does not need to exist anymore. Thread replies are
+// left out unless they were also sent to the conversation.
+func (db *appdbimpl) GetChatPage(conversationId int64, viewerId int64, beforeId *int64, afterId *int64, limit int) ([]MessageView, bool, error) {
+	return db.getMessagePage(`m.conversationId = ? AND m.inConversation`, []interface{}{conversationId}, viewerId, beforeId, afterId, limit)
+}
+
+// GetThreadPage returns, like GetChatPage, a page of the replies in the thread started by rootId.
+func (db *appdbimpl) GetThreadPage(rootId int64, viewerId int64, beforeId *int64, afterId *int64, limit int) ([]MessageView, bool, error) {
+	return db.getMessagePage(`m.threadRootId = ?`, []interface{}{rootId}, viewerId, beforeId, afterId, limit)
+}
+
+// getMessagePage implements the paginated listings over the messages matching scope, a condition on the messages
+// table aliased as m taking scopeArgs.
+func (db *appdbimpl) getMessagePage(scope string, scopeArgs []interface{}, viewerId int64, beforeId *int64, afterId *int64, limit int) ([]MessageView, bool, error) {
+	visible := `SELECT m.id FROM messages m WHERE ` + scope + ` AND ` + visibleTo
+
+	var stmt string
+	var cursor int64
+	switch {
+	case afterId != nil:
+		stmt = visible + ` AND m.id > ? ORDER BY m.id ASC LIMIT ?`
+		cursor = *afterId
+	case beforeId != nil:
+		stmt = visible + ` AND m.id < ? ORDER BY m.id DESC LIMIT ?`
+		cursor = *beforeId
+	default:
+		stmt = visible + ` AND m.id < ? ORDER BY m.id DESC LIMIT ?`
+		cursor = math.MaxInt64
+	}
+
+	// Fetch one more than requested to know if there is another page
+	args := append(append([]interface{}{}, scopeArgs...), viewerId, cursor, limit+1)
+	messageIds, err := db.queryMessageIds(stmt, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messageIds) > limit
+	if hasMore {
+		messageIds = messageIds[:limit]
+	}
+
+	messages, err := db.GetMessagesByIds(messageIds)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].MessageId < messages[j].MessageId
+	})
+
+	return messages, hasMore, nil
+}
+
+// GetMessage returns a message of a conversation, or nil if the conversation has no such message.
+func (db *appdbimpl) GetMessage(conversationId int64, messageId int64) (*MessageView, error) {
+	messages, err := db.getMessageViews("m.id = ? AND m.conversationId = ?", messageId, conversationId)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// GetVisibleMessage returns, like GetMessage, a message of a conversation, or nil if viewerId may not see it.
+func (db *appdbimpl) GetVisibleMessage(conversationId int64, viewerId int64, messageId int64) (*MessageView, error) {
+	messages, err := db.getMessageViews("m.id = ? AND m.conversationId = ? AND "+visibleTo, messageId, conversationId, viewerId)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// GetMessageContext returns, in chronological order, a message of a conversation with up to radius messages before and
+// after it as seen by viewerId, and whether more messages exist on each side. The returned messages are nil if the
+// conversation has no such message, or if the viewer may not see it.
+func (db *appdbimpl) GetMessageContext(conversationId int64, viewerId int64, messageId int64, radius int) ([]MessageView, bool, bool, error) {
+	target, err := db.getMessageViews("m.id = ? AND m.conversationId = ? AND "+visibleTo, messageId, conversationId, viewerId)
+	if err != nil || len(target) == 0 {
+		return nil, false, false, err
+	}
+
+	before, hasMoreBefore, err := db.GetChatPage(conversationId, viewerId, &messageId, nil, radius)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	after, hasMoreAfter, err := db.GetChatPage(conversationId, viewerId, nil, &messageId, radius)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	messages := make([]MessageView, 0, len(before)+1+len(after))
+	messages = append(messages, before...)
+	messages = append(messages, target...)
+	messages = append(messages, after...)
+	return messages, hasMoreBefore, hasMoreAfter, nil
+}
+
+// queryMessageIds runs a query selecting message IDs and collects them in order.
+func (db *appdbimpl) queryMessageIds(stmt string, args ...interface{}) ([]int64, error) {
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var messageIds []int64
+	for rows.Next() {
+		var messageId int64
+		if err := rows.Scan(&messageId); err != nil {
+			return nil, err
+		}
+		messageIds = append(messageIds, messageId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messageIds, nil
+}
+
+// GetMessagesByIds returns the messages with the given IDs that still exist, in no particular order.
+func (db *appdbimpl) GetMessagesByIds(messageIds []int64) ([]MessageView, error) {
+	if len(messageIds) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(messageIds)-1) + "?"
+	args := make([]interface{}, 0, len(messageIds))
+	for _, id := range messageIds {
+		args = append(args, id)
+	}
+
+	return db.getMessageViews("m.id IN ("+placeholders+")", args...)
 }
 
-func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
-	const stmt = `
+// GetVisibleMessagesByIds returns, like GetMessagesByIds, the messages with the given IDs, leaving out the ones
+// viewerId may not see.
+func (db *appdbimpl) GetVisibleMessagesByIds(viewerId int64, messageIds []int64) ([]MessageView, error) {
+	if len(messageIds) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(messageIds)-1) + "?"
+	args := make([]interface{}, 0, len(messageIds)+1)
+	for _, id := range messageIds {
+		args = append(args, id)
+	}
+	args = append(args, viewerId)
+
+	return db.getMessageViews("m.id IN ("+placeholders+") AND "+visibleTo, args...)
+}
+
+// getMessageViews loads the messages matching filter, a condition on the messages table aliased as m, together with
+// their sender, photo, file, reactions, mentions, poll and aggregated status.
+func (db *appdbimpl) getMessageViews(filter string, args ...interface{}) ([]MessageView, error) {
+	stmt := `
 	SELECT 
 		m.id                  AS messageId,
 		m.content             AS messageText,
@@ -49,7 +493,17 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 		i.path                AS messagePhotoPath,
 		m.isForwarded         AS isForwarded,
 		m.replyTo,
+		m.threadRootId,
+		m.inConversation,
+		EXISTS (SELECT 1 FROM pins pn WHERE pn.messageId = m.id) AS messagePinned,
+		(SELECT COUNT(*) FROM messages t WHERE t.threadRootId = m.id AND t.deletedAt IS NULL) AS threadReplyCount,
+		(SELECT strftime('%Y-%m-%dT%H:%M:%SZ', MAX(t.timestamp)) FROM messages t WHERE t.threadRootId = m.id AND t.deletedAt IS NULL) AS lastThreadReplyAt,
 		m.timestamp           AS messageTimestamp,
+		m.editedAt            AS messageEditedAt,
+		m.deletedAt IS NOT NULL AS messageDeleted,
+		m.expiresAt           AS messageExpiresAt,
+		m.kind                AS messageKind,
+		m.systemData          AS messageSystemData,
 		u.id                  AS messageSenderId,
 		u.username            AS messageSenderUsername,
 		u.photoId             AS messageSenderPhotoId,
@@ -67,18 +521,17 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 	LEFT JOIN images i ON m.photoId = i.uuid
 	LEFT JOIN images ui on u.photoId = ui.uuid
 	LEFT JOIN images ri on ru.photoId = ri.uuid
-	WHERE m.conversationId = ?
-	`
+	WHERE ` + filter
 
-	const statusStmt = `
+	statusStmt := `
 	SELECT messageId, status 
 	FROM message_status 
 	WHERE messageId IN (
-		SELECT id FROM messages WHERE conversationId = ?
+		SELECT m.id FROM messages m WHERE ` + filter + `
 	)
 	`
 
-	statusRows, err := db.c.Query(statusStmt, conversationID)
+	statusRows, err := db.c.Query(statusStmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +551,7 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 		return nil, err
 	}
 
-	rows, err := db.c.Query(stmt, conversationID)
+	rows, err := db.c.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +568,17 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 			nsMessagePhotoID          sql.NullString
 			nsMessagePhotoPath        sql.NullString
 			nrReplyTo                 sql.NullInt64
+			nrThreadRootId            sql.NullInt64
+			inConversation            bool
+			pinned                    bool
+			threadReplyCount          int64
+			nsLastThreadReplyAt       sql.NullString
 			messageTimestamp          string
+			nsMessageEditedAt         sql.NullString
+			deleted                   bool
+			nsMessageExpiresAt        sql.NullString
+			kind                      string
+			nsSystemData              sql.NullString
 			senderID                  int64
 			senderUsername            string
 			nsSenderPhotoID           sql.NullString
@@ -136,7 +599,17 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 			&nsMessagePhotoPath,
 			&isForwarded,
 			&nrReplyTo,
+			&nrThreadRootId,
+			&inConversation,
+			&pinned,
+			&threadReplyCount,
+			&nsLastThreadReplyAt,
 			&messageTimestamp,
+			&nsMessageEditedAt,
+			&deleted,
+			&nsMessageExpiresAt,
+			&kind,
+			&nsSystemData,
 			&senderID,
 			&senderUsername,
 			&nsSenderPhotoID,
@@ -169,8 +642,36 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 			messageText = &nsmessageText.String
 		}
 
+		var editedAt *string
+		if nsMessageEditedAt.Valid {
+			editedAt = &nsMessageEditedAt.String
+		}
+
 		msg, ok := msgMap[messageID]
 		if !ok {
+			var expiresAt *string
+			if nsMessageExpiresAt.Valid {
+				expiresAt = &nsMessageExpiresAt.String
+			}
+
+			var threadRootId *int64
+			if nrThreadRootId.Valid {
+				threadRootId = &nrThreadRootId.Int64
+			}
+
+			var lastThreadReplyAt *string
+			if nsLastThreadReplyAt.Valid {
+				lastThreadReplyAt = &nsLastThreadReplyAt.String
+			}
+
+			var system *SystemData
+			if nsSystemData.Valid {
+				system = &SystemData{}
+				if err := json.Unmarshal([]byte(nsSystemData.String), system); err != nil {
+					return nil, err
+				}
+			}
+
 			var senderPhoto *Photo
 			if nsSenderPhotoID.Valid && nsSenderPhotoPath.Valid {
 				senderPhoto = &Photo{
@@ -193,7 +694,17 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 				ConversationId: convID,
 				Photo:          photo,
 				ReplyTo:        replyTo,
+				ThreadRootId:   threadRootId,
+				InConversation: inConversation,
+				Pinned:         pinned,
+				ThreadReplies:  threadReplyCount,
+				LastReplyAt:    lastThreadReplyAt,
 				Timestamp:      messageTimestamp,
+				EditedAt:       editedAt,
+				Deleted:        deleted,
+				ExpiresAt:      expiresAt,
+				Kind:           kind,
+				System:         system,
 				SentBy: User{
 					UserId:   senderID,
 					Username: senderUsername,
@@ -271,23 +782,62 @@ func (db *appdbimpl) GetChat(conversationID int64) ([]MessageView, error) {
 		}
 	}
 
+	mentionMap, err := db.getMentions(filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	for id, mentions := range mentionMap {
+		if m, exists := msgMap[id]; exists {
+			m.Mentions = mentions
+		}
+	}
+
+	fileMap, err := db.getMessageFiles(filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	for id, file := range fileMap {
+		if m, exists := msgMap[id]; exists {
+			m.File = file
+		}
+	}
+
+	pollMap, err := db.getPolls(filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	for id, poll := range pollMap {
+		if m, exists := msgMap[id]; exists {
+			m.Poll = poll
+		}
+	}
+
+	for _, m := range msgMap {
+		if m.System == nil || m.System.UserId == nil {
+			continue
+		}
+		user, err := db.GetUser(*m.System.UserId)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		m.System.User = user
+	}
+
 	var out []MessageView
 	for _, m := range msgMap {
 		out = append(out, *m)
 	}
 
-	sort.Slice(out, func(i, j int) bool {
-		return out[i].Timestamp < out[j].Timestamp
-	})
-
 	return out, nil
 }
 
 func (db *appdbimpl) ForwardMessage(messageIdToForward int64, conversationId int64, forwarderId int64) (messageId int64, timestamp string, content *string, photoId *string, err error) {
-	stmt := `SELECT content, photoId FROM messages WHERE id = ?`
+	stmt := `SELECT kind, content, photoId, fileId FROM messages WHERE id = ?`
+	var kind string
 	var nsText sql.NullString
 	var nsPhotoId sql.NullString
-	err = db.c.QueryRow(stmt, messageIdToForward).Scan(&content, &nsPhotoId)
+	var nsFileId sql.NullString
+	err = db.c.QueryRow(stmt, messageIdToForward).Scan(&kind, &content, &nsPhotoId, &nsFileId)
 	if err != nil {
 		return 0, "", nil, nil, err
 	}
@@ -300,7 +850,14 @@ func (db *appdbimpl) ForwardMessage(messageIdToForward int64, conversationId int
 		content = &nsText.String
 	}
 
-	forwardedMessageId, timestamp, err := db.InsertMessage(conversationId, forwarderId, content, photoId, nil, true)
+	// The forwarded message shares the file, which the forwarder's conversation can then download too
+	var fileId *string
+	if nsFileId.Valid {
+		fileId = &nsFileId.String
+	}
+
+	// Voice messages stay voice messages
+	forwardedMessageId, timestamp, err := insertMessage(db.c, conversationId, forwarderId, kind, content, photoId, fileId, nil, nil, true, true)
 	if err != nil {
 		return 0, "", nil, nil, err
 	}
@@ -318,76 +875,14 @@ func (db *appdbimpl) GetConversationIdFromMessageId(messageId int64) (int64, err
 	return conversationId, nil
 }
 
-func (db *appdbimpl) GetLastMessage(conversationId int64) (*MessageView, error) {
-
-	empty, err := db.IsConversationEmpty(conversationId)
-	if err != nil {
-		return nil, err
-	}
-	if empty {
-		return nil, nil
-	}
-
-	stmt := `SELECT m.id, m.content, m.photoId, i.path, m.replyTo, m.timestamp, u.id, u.username, u.photoId, ui.path, m.isForwarded
-			FROM messages m
-			LEFT JOIN images i ON m.photoId = i.uuid
-			JOIN users u ON m.senderId = u.id
-			LEFT JOIN images ui ON u.photoId = ui.uuid
-			WHERE m.conversationId = ?
-			ORDER BY m.timestamp DESC
-			LIMIT 1`
-
-	var msg MessageView
-	var nsText sql.NullString
-	var nsPhotoId sql.NullString
-	var nsPhotoPath sql.NullString
-	var nsReplyTo sql.NullInt64
-	var nsSenderPhotoId sql.NullString
-	var nsSenderPhotoPath sql.NullString
-	var isForwarded bool
-
-	err = db.c.QueryRow(stmt, conversationId).Scan(
-		&msg.MessageId,
-		&nsText,
-		&nsPhotoId,
-		&nsPhotoPath,
-		&nsReplyTo,
-		&msg.Timestamp,
-		&msg.SentBy.UserId,
-		&msg.SentBy.Username,
-		&nsSenderPhotoId,
-		&nsSenderPhotoPath,
-		&isForwarded,
-	)
-	if err != nil {
+// GetLastMessage returns the most recent message of a conversation that viewerId has not hidden, or nil if there is
+// none. Thread replies only count if they were also sent to the conversation.
+func (db *appdbimpl) GetLastMessage(conversationId int64, viewerId int64) (*MessageView, error) {
+	messages, err := db.getMessageViews(`m.id = (SELECT MAX(m.id) FROM messages m WHERE m.conversationId = ? AND m.inConversation AND `+visibleTo+`)`, conversationId, viewerId)
+	if err != nil || len(messages) == 0 {
 		return nil, err
 	}
-
-	if nsText.Valid {
-		msg.Text = &nsText.String
-	}
-
-	if nsPhotoId.Valid && nsPhotoPath.Valid {
-		msg.Photo = &Photo{
-			PhotoId: nsPhotoId.String,
-			Path:    nsPhotoPath.String,
-		}
-	}
-
-	if nsReplyTo.Valid {
-		msg.ReplyTo = &nsReplyTo.Int64
-	}
-
-	if nsSenderPhotoId.Valid && nsSenderPhotoPath.Valid {
-		msg.SentBy.Photo = &Photo{
-			PhotoId: nsSenderPhotoId.String,
-			Path:    nsSenderPhotoPath.String,
-		}
-	}
-	msg.IsForwarded = isForwarded
-	msg.Status = "sent"
-
-	return &msg, nil
+	return &messages[0], nil
 }
 
 func (db *appdbimpl) IsConversationEmpty(conversationId int64) (bool, error) {
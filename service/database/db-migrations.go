@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// addedColumns are the columns added to existing tables since they were first created. initdb.sql only creates the
+// tables that are missing, so databases created by earlier versions get these columns through ALTER TABLE.
+var addedColumns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"sessions", "deviceLabel", "deviceLabel TEXT"},
+	{"sessions", "userAgent", "userAgent TEXT"},
+	{"sessions", "ipAddress", "ipAddress TEXT"},
+	{"conversations", "messageTtl", "messageTtl INTEGER"},
+	{"conversations", "renamePermission", "renamePermission TEXT NOT NULL DEFAULT 'members' CHECK (renamePermission IN ('members', 'admins'))"},
+	{"conversations", "photoPermission", "photoPermission TEXT NOT NULL DEFAULT 'members' CHECK (photoPermission IN ('members', 'admins'))"},
+	{"conversations", "addPermission", "addPermission TEXT NOT NULL DEFAULT 'members' CHECK (addPermission IN ('members', 'admins'))"},
+	{"conversations", "pinPermission", "pinPermission TEXT NOT NULL DEFAULT 'members' CHECK (pinPermission IN ('members', 'admins'))"},
+	{"conversations", "historyVisibility", "historyVisibility TEXT NOT NULL DEFAULT 'full' CHECK (historyVisibility IN ('full', 'joined'))"},
+	{"files", "durationMs", "durationMs INTEGER"},
+	{"files", "waveform", "waveform BLOB"},
+	{"changes", "forUserId", "forUserId INTEGER"},
+}
+
+// rebuiltTables are the tables whose table constraints or column defaults changed in ways ALTER TABLE cannot apply.
+// A table that lacks the given column predates the change, and is recreated from initdb.sql with its rows copied over;
+// the backfill statements then fill in the new columns of the copied rows.
+var rebuiltTables = []struct {
+	table    string
+	column   string
+	backfill []string
+}{
+	// Checks allowing messages without content: deleted, system and file messages, and thread replies
+	{"messages", "fileId", nil},
+	{"scheduled_messages", "fileId", nil},
+	// joinedAt defaults to the current time, which ALTER TABLE does not allow. Members from before it are considered
+	// to have joined with the first message of their conversation, and groups from before roles are owned by their
+	// longest-standing member.
+	{"participants", "joinedAt", []string{
+		`UPDATE participants SET joinedAt = COALESCE(
+			(SELECT MIN(m.timestamp) FROM messages m WHERE m.conversationId = participants.conversationId), joinedAt)`,
+		`UPDATE participants SET role = 'owner'
+		 WHERE rowid IN (SELECT MIN(p.rowid) FROM participants p
+		                 JOIN conversations c ON c.id = p.conversationId
+		                 WHERE c.isGroup
+		                   AND NOT EXISTS (SELECT 1 FROM participants o WHERE o.conversationId = p.conversationId AND o.role = 'owner')
+		                 GROUP BY p.conversationId)`,
+	}},
+}
+
+// migrate brings the tables of a database created by an earlier version up to date, before initdb.sql creates what is
+// missing. It does nothing on a new database.
+func migrate(db *sql.DB, initdb string) error {
+	// Foreign keys must be off while tables are rebuilt, and the setting only applies to one connection
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.ExecContext(context.Background(), `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer func() { _, _ = conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON`) }()
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, rebuilt := range rebuiltTables {
+		columns, err := tableColumns(tx, rebuilt.table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 || columns[rebuilt.column] {
+			continue
+		}
+		if err := rebuildTable(tx, initdb, rebuilt.table, columns); err != nil {
+			return fmt.Errorf("rebuilding %s: %w", rebuilt.table, err)
+		}
+		for _, stmt := range rebuilt.backfill {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("filling in %s: %w", rebuilt.table, err)
+			}
+		}
+	}
+
+	for _, added := range addedColumns {
+		columns, err := tableColumns(tx, added.table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 || columns[added.column] {
+			continue
+		}
+		if _, err := tx.Exec(`ALTER TABLE "` + added.table + `" ADD COLUMN ` + added.definition); err != nil {
+			return fmt.Errorf("adding %s.%s: %w", added.table, added.column, err)
+		}
+	}
+
+	// Rows copied with foreign keys off must still satisfy them
+	rows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	violation := rows.Next()
+	helpers.CloseRows(rows)
+	if violation {
+		return fmt.Errorf("migrated tables violate foreign keys")
+	}
+
+	return tx.Commit()
+}
+
+// tableColumns returns the set of the columns of a table, empty if the table does not exist.
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// rebuildTable recreates a table with its definition in initdb, keeping the rows and the values of the columns that
+// are still defined. Its indexes and triggers are dropped with it, and recreated by initdb.
+func rebuildTable(tx *sql.Tx, initdb string, table string, oldColumns map[string]bool) error {
+	definition := regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS "` + regexp.QuoteMeta(table) + `" \(.*?\n\);`).FindString(initdb)
+	if definition == "" {
+		return fmt.Errorf("no definition in initdb.sql")
+	}
+	migrated := table + "_migrated"
+	definition = strings.Replace(definition, `CREATE TABLE IF NOT EXISTS "`+table+`"`, `CREATE TABLE "`+migrated+`"`, 1)
+	if _, err := tx.Exec(definition); err != nil {
+		return err
+	}
+
+	newColumns, err := tableColumns(tx, migrated)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for column := range newColumns {
+		if oldColumns[column] {
+			kept = append(kept, `"`+column+`"`)
+		}
+	}
+	columnList := strings.Join(kept, ", ")
+
+	stmts := []string{
+		`INSERT INTO "` + migrated + `" (` + columnList + `) SELECT ` + columnList + ` FROM "` + table + `"`,
+		`DROP TABLE "` + table + `"`,
+		`ALTER TABLE "` + migrated + `" RENAME TO "` + table + `"`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
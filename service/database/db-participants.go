@@ -6,31 +6,62 @@ import (
 	"github.com/Reewd/WASAproject/service/database/helpers"
 )
 
-func (db *appdbimpl) InsertParticipants(conversationId int64, userId []int64) error {
-	stmt := `INSERT INTO participants (conversationId, userId) VALUES (?, ?)`
+// InsertParticipants adds users to a conversation, and returns the ones that were added. Users who already take part
+// in it are skipped, so that adding them and approving their request to join cannot race into duplicates. Users who
+// left it are added back with a new joining time.
+func (db *appdbimpl) InsertParticipants(conversationId int64, userId []int64) ([]int64, error) {
+	rejoin := `UPDATE participants SET joinedAt = CURRENT_TIMESTAMP, leftAt = NULL, role = ?
+			   WHERE conversationId = ? AND userId = ? AND leftAt IS NOT NULL`
+	stmt := `INSERT INTO participants (conversationId, userId)
+			 SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM participants WHERE conversationId = ? AND userId = ?)`
+	var added []int64
 	for _, id := range userId {
-		_, err := db.c.Exec(stmt, conversationId, id)
+		result, err := db.c.Exec(rejoin, RoleMember, conversationId, id)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 0 {
+			result, err = db.c.Exec(stmt, conversationId, id, conversationId, id)
+			if err != nil {
+				return nil, err
+			}
+			affected, err = result.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if affected == 0 {
+			continue
+		}
+		added = append(added, id)
+		if err := db.recordChange(conversationId, ChangeParticipantAdded, nil, &id); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return added, nil
 }
 
+// RemoveParticipant makes userId leave a conversation. The participation is kept with the time of leaving, so that
+// joining again starts a new one.
 func (db *appdbimpl) RemoveParticipant(conversationId int64, userId int64) error {
-	stmt := `DELETE FROM participants WHERE conversationId = ? AND userId = ?`
-	_, err := db.c.Exec(stmt, conversationId, userId)
+	stmt := `UPDATE participants SET leftAt = CURRENT_TIMESTAMP, role = ?
+			 WHERE conversationId = ? AND userId = ? AND leftAt IS NULL`
+	_, err := db.c.Exec(stmt, RoleMember, conversationId, userId)
 	if err != nil {
 		return err
 	}
-	return nil
+	return db.recordChange(conversationId, ChangeParticipantRemoved, nil, &userId)
 }
 
 func (db *appdbimpl) GetParticipants(conversationId int64) ([]User, error) {
-	stmt := `SELECT u.id, u.username, u.photoId, i.path FROM participants p
+	stmt := `SELECT u.id, u.username, u.photoId, i.path, p.role, strftime('%Y-%m-%dT%H:%M:%SZ', p.joinedAt) FROM participants p
 		 JOIN users u ON p.userId = u.id
 		 LEFT JOIN images i ON u.photoId = i.uuid
-		 WHERE p.conversationId = ?`
+		 WHERE p.conversationId = ? AND p.leftAt IS NULL`
 	rows, err := db.c.Query(stmt, conversationId)
 	if err != nil {
 		return nil, err
@@ -42,7 +73,7 @@ func (db *appdbimpl) GetParticipants(conversationId int64) ([]User, error) {
 		var participant User
 		var nsPhotoId sql.NullString
 		var nsPhotoPath sql.NullString
-		err := rows.Scan(&participant.UserId, &participant.Username, &nsPhotoId, &nsPhotoPath)
+		err := rows.Scan(&participant.UserId, &participant.Username, &nsPhotoId, &nsPhotoPath, &participant.Role, &participant.JoinedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -63,7 +94,7 @@ func (db *appdbimpl) GetParticipants(conversationId int64) ([]User, error) {
 }
 
 func (db *appdbimpl) GetParticipantIds(conversationId int64) ([]int64, error) {
-	stmt := `SELECT userId FROM participants WHERE conversationId = ?`
+	stmt := `SELECT userId FROM participants WHERE conversationId = ? AND leftAt IS NULL`
 	rows, err := db.c.Query(stmt, conversationId)
 	if err != nil {
 		return nil, err
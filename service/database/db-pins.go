@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// InsertPin pins a message of a conversation on behalf of pinnedBy, unless the conversation already has maxPins pinned
+// messages. It reports whether the message was pinned; pinning a message again has no effect and reports true.
+func (db *appdbimpl) InsertPin(conversationId int64, messageId int64, pinnedBy int64, maxPins int) (bool, error) {
+	var pinned bool
+	err := db.c.QueryRow(`SELECT EXISTS (SELECT 1 FROM pins WHERE messageId = ?)`, messageId).Scan(&pinned)
+	if err != nil {
+		return false, err
+	}
+	if pinned {
+		return true, nil
+	}
+
+	// The limit is checked by the insertion itself, so that concurrent pins cannot exceed it
+	stmt := `INSERT INTO pins (messageId, conversationId, pinnedBy)
+			 SELECT ?, ?, ? WHERE (SELECT COUNT(*) FROM pins WHERE conversationId = ?) < ?
+			 ON CONFLICT (messageId) DO NOTHING`
+	result, err := db.c.Exec(stmt, messageId, conversationId, pinnedBy, conversationId, maxPins)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	return true, db.recordChange(conversationId, ChangePinChanged, &messageId, &pinnedBy)
+}
+
+// RemovePin unpins a message of a conversation, and reports whether it was pinned.
+func (db *appdbimpl) RemovePin(conversationId int64, messageId int64, userId int64) (bool, error) {
+	result, err := db.c.Exec(`DELETE FROM pins WHERE messageId = ? AND conversationId = ?`, messageId, conversationId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	return true, db.recordChange(conversationId, ChangePinChanged, &messageId, &userId)
+}
+
+// GetPins returns the pinned messages of a conversation that viewerId may see, the most recently pinned first.
+func (db *appdbimpl) GetPins(conversationId int64, viewerId int64) ([]Pin, error) {
+	stmt := `SELECT pn.messageId, pn.pinnedAt, u.id, u.username, u.photoId, i.path
+			 FROM pins pn
+			 JOIN users u ON pn.pinnedBy = u.id
+			 LEFT JOIN images i ON u.photoId = i.uuid
+			 WHERE pn.conversationId = ?
+			 ORDER BY pn.pinnedAt DESC, pn.rowid DESC`
+	rows, err := db.c.Query(stmt, conversationId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var pins []Pin
+	var messageIds []int64
+	for rows.Next() {
+		var pin Pin
+		var nsPhotoId sql.NullString
+		var nsPhotoPath sql.NullString
+		if err := rows.Scan(&pin.Message.MessageId, &pin.PinnedAt, &pin.PinnedBy.UserId, &pin.PinnedBy.Username, &nsPhotoId, &nsPhotoPath); err != nil {
+			return nil, err
+		}
+		if nsPhotoId.Valid && nsPhotoPath.Valid {
+			pin.PinnedBy.Photo = &Photo{
+				PhotoId: nsPhotoId.String,
+				Path:    nsPhotoPath.String,
+			}
+		}
+		pins = append(pins, pin)
+		messageIds = append(messageIds, pin.Message.MessageId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	messages, err := db.GetVisibleMessagesByIds(viewerId, messageIds)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[int64]MessageView, len(messages))
+	for _, msg := range messages {
+		byId[msg.MessageId] = msg
+	}
+
+	loaded := make([]Pin, 0, len(pins))
+	for _, pin := range pins {
+		if msg, ok := byId[pin.Message.MessageId]; ok {
+			pin.Message = msg
+			loaded = append(loaded, pin)
+		}
+	}
+	return loaded, nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// InsertPoll stores a new message of kind KindPoll, whose text is the question, together with its options. Like
+// InsertMessage, a poll posted in the thread of threadRootId only appears in the conversation if inConversation is set.
+// The message and the poll are stored in one transaction, so a poll never lacks its options.
+func (db *appdbimpl) InsertPoll(conversationId int64, userId int64, poll NewPoll, replyTo *int64, threadRootId *int64, inConversation bool) (int64, error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	messageId, _, err := insertMessage(tx, conversationId, userId, KindPoll, &poll.Question, nil, nil, replyTo, threadRootId, inConversation, false)
+	if err != nil {
+		return 0, err
+	}
+
+	var closesAt *string
+	if poll.ClosesAt != nil {
+		formatted := poll.ClosesAt.UTC().Format(sqliteTimeLayout)
+		closesAt = &formatted
+	}
+	stmt := `INSERT INTO polls (messageId, multipleChoice, anonymous, closesAt) VALUES (?, ?, ?, ?)`
+	if _, err := tx.Exec(stmt, messageId, poll.MultipleChoice, poll.Anonymous, closesAt); err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO poll_options (messageId, position, content) VALUES (?, ?, ?)`
+	for position, option := range poll.Options {
+		if _, err := tx.Exec(stmt, messageId, position, option); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return messageId, nil
+}
+
+// SetPollVotes replaces the votes of userId in a poll with the options at the given positions, which the caller has
+// validated. No positions withdraws the user's vote. The votes are replaced in one transaction, so a failure keeps the
+// previous ones.
+func (db *appdbimpl) SetPollVotes(messageId int64, userId int64, positions []int) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM poll_votes WHERE messageId = ? AND userId = ?`, messageId, userId); err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO poll_votes (messageId, position, userId) VALUES (?, ?, ?)`
+	for _, position := range positions {
+		if _, err := tx.Exec(stmt, messageId, position, userId); err != nil {
+			return err
+		}
+	}
+	if err := recordMessageChangeOn(tx, ChangePollChanged, messageId, &userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPollVotes returns the positions of the options userId voted for in a poll, in order.
+func (db *appdbimpl) GetPollVotes(messageId int64, userId int64) ([]int, error) {
+	rows, err := db.c.Query(`SELECT position FROM poll_votes WHERE messageId = ? AND userId = ? ORDER BY position`, messageId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	positions := []int{}
+	for rows.Next() {
+		var position int
+		if err := rows.Scan(&position); err != nil {
+			return nil, err
+		}
+		positions = append(positions, position)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// removePoll deletes the options, votes and settings of a poll message, if it is one.
+func removePoll(q querier, messageId int64) error {
+	for _, stmt := range []string{
+		`DELETE FROM poll_votes WHERE messageId = ?`,
+		`DELETE FROM poll_options WHERE messageId = ?`,
+		`DELETE FROM polls WHERE messageId = ?`,
+	} {
+		if _, err := q.Exec(stmt, messageId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPolls loads the polls among the messages matching filter, a condition on the messages table aliased as m, by
+// message ID. The voters are only loaded for polls that are not anonymous.
+func (db *appdbimpl) getPolls(filter string, args ...interface{}) (map[int64]*Poll, error) {
+	stmt := `SELECT pl.messageId, pl.multipleChoice, pl.anonymous,
+			        strftime('%Y-%m-%dT%H:%M:%SZ', pl.closesAt), COALESCE(pl.closesAt <= CURRENT_TIMESTAMP, FALSE),
+			        o.position, o.content
+			 FROM polls pl
+			 JOIN poll_options o ON o.messageId = pl.messageId
+			 WHERE pl.messageId IN (SELECT m.id FROM messages m WHERE ` + filter + `)
+			 ORDER BY pl.messageId, o.position`
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	polls := make(map[int64]*Poll)
+	for rows.Next() {
+		var messageId int64
+		var settings Poll
+		var nsClosesAt sql.NullString
+		var position int
+		var option PollOption
+		if err := rows.Scan(&messageId, &settings.MultipleChoice, &settings.Anonymous, &nsClosesAt, &settings.Closed, &position, &option.Text); err != nil {
+			return nil, err
+		}
+
+		poll, ok := polls[messageId]
+		if !ok {
+			if nsClosesAt.Valid {
+				settings.ClosesAt = &nsClosesAt.String
+			}
+			poll = &settings
+			polls[messageId] = poll
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(polls) == 0 {
+		return polls, nil
+	}
+
+	votesStmt := `SELECT v.messageId, v.position, u.id, u.username, u.photoId, i.path
+				  FROM poll_votes v
+				  JOIN users u ON v.userId = u.id
+				  LEFT JOIN images i ON u.photoId = i.uuid
+				  WHERE v.messageId IN (SELECT m.id FROM messages m WHERE ` + filter + `)
+				  ORDER BY v.messageId, v.votedAt, u.id`
+	voteRows, err := db.c.Query(votesStmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(voteRows)
+
+	voters := make(map[int64]map[int64]bool)
+	for voteRows.Next() {
+		var messageId int64
+		var position int
+		var voter User
+		var nsPhotoId sql.NullString
+		var nsPhotoPath sql.NullString
+		if err := voteRows.Scan(&messageId, &position, &voter.UserId, &voter.Username, &nsPhotoId, &nsPhotoPath); err != nil {
+			return nil, err
+		}
+
+		poll, ok := polls[messageId]
+		if !ok || position < 0 || position >= len(poll.Options) {
+			continue
+		}
+		if voters[messageId] == nil {
+			voters[messageId] = make(map[int64]bool)
+		}
+		if !voters[messageId][voter.UserId] {
+			voters[messageId][voter.UserId] = true
+			poll.VoterCount++
+		}
+
+		option := &poll.Options[position]
+		option.Votes++
+		if !poll.Anonymous {
+			if nsPhotoId.Valid && nsPhotoPath.Valid {
+				voter.Photo = &Photo{
+					PhotoId: nsPhotoId.String,
+					Path:    nsPhotoPath.String,
+				}
+			}
+			option.Voters = append(option.Voters, voter)
+		}
+	}
+
+	if err := voteRows.Err(); err != nil {
+		return nil, err
+	}
+	return polls, nil
+}
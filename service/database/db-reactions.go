@@ -15,7 +15,10 @@ func (db *appdbimpl) InsertReaction(messageId, senderId int64, content string) e
           timestamp = CURRENT_TIMESTAMP
     `
 	_, err := db.c.Exec(stmt, messageId, senderId, content)
-	return err
+	if err != nil {
+		return err
+	}
+	return db.recordMessageChange(ChangeReactionChanged, messageId, &senderId)
 }
 
 func (db *appdbimpl) RemoveReaction(messageId, senderId int64) error {
@@ -24,7 +27,7 @@ func (db *appdbimpl) RemoveReaction(messageId, senderId int64) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return db.recordMessageChange(ChangeReactionChanged, messageId, &senderId)
 }
 
 func (db *appdbimpl) GetReactions(messageId int64) ([]ReactionView, error) {
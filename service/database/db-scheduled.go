@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// sqliteTimeLayout is the format of CURRENT_TIMESTAMP, so that stored times compare correctly with it.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+func (db *appdbimpl) InsertScheduledMessage(conversationId int64, senderId int64, content *string, photoId *string, fileId *string, replyTo *int64, threadRootId *int64, inConversation bool, sendAt time.Time) (int64, error) {
+	stmt := `INSERT INTO scheduled_messages (conversationId, senderId, content, photoId, fileId, replyTo, threadRootId, inConversation, sendAt)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := db.c.Exec(stmt, conversationId, senderId, content, photoId, fileId, replyTo, threadRootId, inConversation, sendAt.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetScheduledMessage returns a pending message of senderId, or nil if the sender has no such message.
+func (db *appdbimpl) GetScheduledMessage(scheduledId int64, senderId int64) (*ScheduledMessage, error) {
+	scheduled, err := db.queryScheduledMessages(`s.id = ? AND s.senderId = ?`, scheduledId, senderId)
+	if err != nil || len(scheduled) == 0 {
+		return nil, err
+	}
+	return &scheduled[0], nil
+}
+
+// GetScheduledMessages returns the pending messages of senderId, the next to be sent first.
+func (db *appdbimpl) GetScheduledMessages(senderId int64) ([]ScheduledMessage, error) {
+	return db.queryScheduledMessages(`s.senderId = ? ORDER BY s.sendAt, s.id`, senderId)
+}
+
+// GetDueScheduledMessages returns at most limit pending messages whose time has come, the oldest first.
+func (db *appdbimpl) GetDueScheduledMessages(limit int) ([]ScheduledMessage, error) {
+	return db.queryScheduledMessages(`s.sendAt <= CURRENT_TIMESTAMP ORDER BY s.sendAt, s.id LIMIT ?`, limit)
+}
+
+// UpdateScheduledMessageTime changes when a pending message of senderId is sent, and reports whether it was found.
+func (db *appdbimpl) UpdateScheduledMessageTime(scheduledId int64, senderId int64, sendAt time.Time) (bool, error) {
+	stmt := `UPDATE scheduled_messages SET sendAt = ? WHERE id = ? AND senderId = ?`
+	result, err := db.c.Exec(stmt, sendAt.UTC().Format(sqliteTimeLayout), scheduledId, senderId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SendScheduledMessage turns a pending message into a message of its conversation, as if its sender sent it now, and
+// returns the ID of the new message. The pending message is claimed and the message stored in one transaction, so a
+// failure leaves it pending for the next attempt. sent is false if the message was cancelled in the meantime, or if
+// its sender has left the conversation, in which case it is dropped.
+func (db *appdbimpl) SendScheduledMessage(scheduledId int64) (messageId int64, sent bool, err error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := `DELETE FROM scheduled_messages WHERE id = ?
+			 RETURNING conversationId, senderId, content, photoId, fileId, replyTo, threadRootId, inConversation`
+	var conversationId, senderId int64
+	var content, photoId, fileId *string
+	var replyTo, threadRootId *int64
+	var inConversation bool
+	err = tx.QueryRow(stmt, scheduledId).Scan(&conversationId, &senderId, &content, &photoId, &fileId, &replyTo, &threadRootId, &inConversation)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	stmt = `SELECT EXISTS(SELECT 1 FROM participants WHERE conversationId = ? AND userId = ? AND leftAt IS NULL)`
+	if err := tx.QueryRow(stmt, conversationId, senderId).Scan(&sent); err != nil {
+		return 0, false, err
+	}
+	if sent {
+		messageId, _, err = insertMessage(tx, conversationId, senderId, KindMessage, content, photoId, fileId, replyTo, threadRootId, inConversation, false)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return messageId, sent, nil
+}
+
+// RemoveUserScheduledMessage cancels a pending message of senderId, and reports whether it was found.
+func (db *appdbimpl) RemoveUserScheduledMessage(scheduledId int64, senderId int64) (bool, error) {
+	stmt := `DELETE FROM scheduled_messages WHERE id = ? AND senderId = ?`
+	result, err := db.c.Exec(stmt, scheduledId, senderId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// queryScheduledMessages loads the pending messages matching filter, a condition on the scheduled_messages table
+// aliased as s that may end with ORDER BY and LIMIT clauses.
+func (db *appdbimpl) queryScheduledMessages(filter string, args ...interface{}) ([]ScheduledMessage, error) {
+	stmt := `SELECT s.id, s.conversationId, s.senderId, s.content, s.photoId, i.path,
+			        f.uuid, f.path, f.filename, f.size, f.mimeType, f.uploadedBy,
+			        s.replyTo, s.threadRootId, s.inConversation, s.sendAt, s.createdAt
+			 FROM scheduled_messages s
+			 LEFT JOIN images i ON s.photoId = i.uuid
+			 LEFT JOIN files f ON s.fileId = f.uuid
+			 WHERE ` + filter
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var scheduled []ScheduledMessage
+	for rows.Next() {
+		var sm ScheduledMessage
+		var nsContent sql.NullString
+		var nsPhotoId sql.NullString
+		var nsPhotoPath sql.NullString
+		var nfFile nullFile
+		var nsReplyTo sql.NullInt64
+		var nsThreadRootId sql.NullInt64
+		if err := rows.Scan(&sm.ScheduledId, &sm.ConversationId, &sm.SenderId, &nsContent, &nsPhotoId, &nsPhotoPath,
+			&nfFile.FileId, &nfFile.Path, &nfFile.Filename, &nfFile.Size, &nfFile.MimeType, &nfFile.UploadedBy,
+			&nsReplyTo, &nsThreadRootId, &sm.InConversation, &sm.SendAt, &sm.CreatedAt); err != nil {
+			return nil, err
+		}
+		sm.File = nfFile.file()
+		if nsContent.Valid {
+			sm.Text = &nsContent.String
+		}
+		if nsPhotoId.Valid && nsPhotoPath.Valid {
+			sm.Photo = &Photo{
+				PhotoId: nsPhotoId.String,
+				Path:    nsPhotoPath.String,
+			}
+		}
+		if nsReplyTo.Valid {
+			sm.ReplyTo = &nsReplyTo.Int64
+		}
+		if nsThreadRootId.Valid {
+			sm.ThreadRootId = &nsThreadRootId.Int64
+		}
+		scheduled = append(scheduled, sm)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// searchIndexSchema creates the full-text index of the message contents, kept in sync by the triggers. It needs the
+// FTS5 module of SQLite, which the sqlite_fts5 build tag enables.
+const searchIndexSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS "messages_fts" USING fts5(
+    content,
+    content = 'messages',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS "messages_fts_insert" AFTER INSERT ON messages WHEN new.content IS NOT NULL BEGIN
+    INSERT INTO messages_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "messages_fts_delete" AFTER DELETE ON messages WHEN old.content IS NOT NULL BEGIN
+    INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "messages_fts_update" AFTER UPDATE OF content ON messages BEGIN
+    INSERT INTO messages_fts (messages_fts, rowid, content) SELECT 'delete', old.id, old.content WHERE old.content IS NOT NULL;
+    INSERT INTO messages_fts (rowid, content) SELECT new.id, new.content WHERE new.content IS NOT NULL;
+END;
+`
+
+// initSearchIndex creates the full-text index if SQLite has FTS5, and reports whether it has. Without FTS5, messages
+// are searched by substring, and the triggers of an index created by an earlier build are dropped, since they would
+// make every change to the messages fail.
+func initSearchIndex(db *sql.DB) (bool, error) {
+	var fts bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts); err != nil {
+		return false, err
+	}
+	if !fts {
+		for _, trigger := range []string{"messages_fts_insert", "messages_fts_delete", "messages_fts_update"} {
+			if _, err := db.Exec(`DROP TRIGGER IF EXISTS "` + trigger + `"`); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	// Without its triggers, the index is new or missed changes to the messages: it is then built from them
+	var synced bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = 'messages_fts_insert')`).Scan(&synced)
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(searchIndexSchema); err != nil {
+		return false, err
+	}
+	if !synced {
+		if _, err := db.Exec(`INSERT INTO messages_fts (messages_fts) VALUES ('rebuild')`); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ftsQuery turns free text into an FTS5 query matching the messages that contain all of its words, the last one also
+// as a prefix so that results show up while typing. Words are quoted, so FTS5 operators in the text are not
+// interpreted. It returns an empty string if the text has no words.
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with backslash as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// highlightWords delimits the case-insensitive occurrences of words in text with HighlightStart and HighlightEnd,
+// as snippet() does for the full-text index.
+func highlightWords(text string, words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+	return regexp.MustCompile(`(?i)`+strings.Join(quoted, "|")).ReplaceAllString(text, HighlightStart+"$0"+HighlightEnd)
+}
+
+// SearchMessages returns the messages matching text in the conversations viewerId takes part in, narrowed by filter:
+// with the full-text index, best match first, otherwise the messages containing all the words, newest first. It skips
+// the first offset matches, returns at most limit of them and whether more exist.
+func (db *appdbimpl) SearchMessages(viewerId int64, text string, filter SearchFilter, offset int, limit int) ([]SearchHit, bool, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, false, nil
+	}
+
+	var stmt string
+	var args []interface{}
+	order := ` ORDER BY rank, m.id DESC`
+	if db.fts {
+		stmt = `SELECT m.id, snippet(messages_fts, 0, ?, ?, '…', 16)
+				FROM messages_fts
+				JOIN messages m ON m.id = messages_fts.rowid
+				WHERE messages_fts MATCH ?`
+		args = []interface{}{HighlightStart, HighlightEnd, ftsQuery(text)}
+	} else {
+		stmt = `SELECT m.id, m.content FROM messages m WHERE m.content IS NOT NULL`
+		for _, word := range words {
+			stmt += ` AND m.content LIKE ? ESCAPE '\'`
+			args = append(args, "%"+likeEscaper.Replace(word)+"%")
+		}
+		order = ` ORDER BY m.id DESC`
+	}
+	stmt += ` AND EXISTS (SELECT 1 FROM participants p WHERE p.conversationId = m.conversationId AND p.userId = ? AND p.leftAt IS NULL)
+			  AND ` + visibleTo
+	args = append(args, viewerId, viewerId)
+
+	if filter.ConversationId != nil {
+		stmt += ` AND m.conversationId = ?`
+		args = append(args, *filter.ConversationId)
+	}
+	if filter.SenderId != nil {
+		stmt += ` AND m.senderId = ?`
+		args = append(args, *filter.SenderId)
+	}
+	if filter.Before != nil {
+		stmt += ` AND m.timestamp < ?`
+		args = append(args, filter.Before.UTC().Format(sqliteTimeLayout))
+	}
+
+	// Fetch one more than requested to know if there is another page
+	stmt += order + ` LIMIT ? OFFSET ?`
+	args = append(args, limit+1, offset)
+
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var messageIds []int64
+	snippets := make(map[int64]string)
+	for rows.Next() {
+		var messageId int64
+		var nsSnippet sql.NullString
+		if err := rows.Scan(&messageId, &nsSnippet); err != nil {
+			return nil, false, err
+		}
+		messageIds = append(messageIds, messageId)
+		snippets[messageId] = nsSnippet.String
+		if !db.fts {
+			snippets[messageId] = highlightWords(nsSnippet.String, words)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messageIds) > limit
+	if hasMore {
+		messageIds = messageIds[:limit]
+	}
+
+	messages, err := db.GetMessagesByIds(messageIds)
+	if err != nil {
+		return nil, false, err
+	}
+	byId := make(map[int64]MessageView, len(messages))
+	for _, msg := range messages {
+		byId[msg.MessageId] = msg
+	}
+
+	hits := make([]SearchHit, 0, len(messageIds))
+	for _, messageId := range messageIds {
+		if msg, ok := byId[messageId]; ok {
+			hits = append(hits, SearchHit{Message: msg, Snippet: snippets[messageId]})
+		}
+	}
+	return hits, hasMore, nil
+}
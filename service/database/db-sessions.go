@@ -0,0 +1,159 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/database/helpers"
+)
+
+// hashToken returns the hex encoded SHA-256 of a session token. Only the hash is stored, so a leaked database does not
+// leak usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (db *appdbimpl) InsertSession(userId int64, token string, ttl time.Duration, deviceLabel *string, userAgent string, ipAddress string) (int64, error) {
+	stmt := `INSERT INTO sessions (tokenHash, userId, deviceLabel, userAgent, ipAddress, expiresAt)
+			 VALUES (?, ?, ?, ?, ?, datetime('now', ?))`
+	result, err := db.c.Exec(stmt, hashToken(token), userId, deviceLabel, userAgent, ipAddress, fmt.Sprintf("+%d seconds", int64(ttl.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (db *appdbimpl) GetSessionByToken(token string) (*Session, error) {
+	stmt := `SELECT id, userId, deviceLabel, userAgent, ipAddress, createdAt, expiresAt, lastUsedAt FROM sessions
+			 WHERE tokenHash = ? AND expiresAt > CURRENT_TIMESTAMP`
+
+	session, err := scanSession(db.c.QueryRow(stmt, hashToken(token)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Unknown or expired token
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *appdbimpl) GetSessions(userId int64) ([]Session, error) {
+	stmt := `SELECT id, userId, deviceLabel, userAgent, ipAddress, createdAt, expiresAt, lastUsedAt FROM sessions
+			 WHERE userId = ? AND expiresAt > CURRENT_TIMESTAMP
+			 ORDER BY lastUsedAt DESC`
+	rows, err := db.c.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var sessions []Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (db *appdbimpl) TouchSession(sessionId int64) error {
+	stmt := `UPDATE sessions SET lastUsedAt = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := db.c.Exec(stmt, sessionId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *appdbimpl) RemoveSession(sessionId int64) error {
+	stmt := `DELETE FROM sessions WHERE id = ?`
+	_, err := db.c.Exec(stmt, sessionId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveUserSession deletes a session only if it belongs to userId. It reports whether a session was deleted.
+func (db *appdbimpl) RemoveUserSession(sessionId int64, userId int64) (bool, error) {
+	stmt := `DELETE FROM sessions WHERE id = ? AND userId = ?`
+	result, err := db.c.Exec(stmt, sessionId, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (db *appdbimpl) RemoveAllSessions(userId int64) error {
+	stmt := `DELETE FROM sessions WHERE userId = ?`
+	_, err := db.c.Exec(stmt, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *appdbimpl) RemoveOtherSessions(userId int64, keepSessionId int64) error {
+	stmt := `DELETE FROM sessions WHERE userId = ? AND id != ?`
+	_, err := db.c.Exec(stmt, userId, keepSessionId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *appdbimpl) RemoveExpiredSessions() error {
+	stmt := `DELETE FROM sessions WHERE expiresAt <= CURRENT_TIMESTAMP`
+	_, err := db.c.Exec(stmt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*Session, error) {
+	var session Session
+	var nsDeviceLabel sql.NullString
+	var nsUserAgent sql.NullString
+	var nsIpAddress sql.NullString
+	err := row.Scan(
+		&session.SessionId,
+		&session.UserId,
+		&nsDeviceLabel,
+		&nsUserAgent,
+		&nsIpAddress,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if nsDeviceLabel.Valid {
+		session.DeviceLabel = &nsDeviceLabel.String
+	}
+	session.UserAgent = nsUserAgent.String
+	session.IpAddress = nsIpAddress.String
+
+	return &session, nil
+}
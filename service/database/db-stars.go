@@ -0,0 +1,29 @@
+package database
+
+// InsertStar stars a message for userId only. Starring a message again has no effect.
+func (db *appdbimpl) InsertStar(userId int64, messageId int64) error {
+	stmt := `INSERT OR IGNORE INTO starred_messages (userId, messageId) VALUES (?, ?)`
+	_, err := db.c.Exec(stmt, userId, messageId)
+	return err
+}
+
+// RemoveStar unstars a message for userId, and reports whether it was starred.
+func (db *appdbimpl) RemoveStar(userId int64, messageId int64) (bool, error) {
+	result, err := db.c.Exec(`DELETE FROM starred_messages WHERE userId = ? AND messageId = ?`, userId, messageId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetStarredPage returns, like GetChatPage, a page of the messages starred by userId. Stars on messages of
+// conversations the user has left are kept but not returned, so they show up again if the user is added back.
+func (db *appdbimpl) GetStarredPage(userId int64, beforeId *int64, limit int) ([]MessageView, bool, error) {
+	const scope = `m.id IN (SELECT s.messageId FROM starred_messages s WHERE s.userId = ?)
+		AND m.conversationId IN (SELECT p.conversationId FROM participants p WHERE p.userId = ? AND p.leftAt IS NULL)`
+	return db.getMessagePage(scope, []interface{}{userId, userId}, userId, beforeId, nil, limit)
+}
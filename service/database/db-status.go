@@ -1,5 +1,7 @@
 package database
 
+import "github.com/Reewd/WASAproject/service/database/helpers"
+
 func (db *appdbimpl) InsertSent(messageId int64, conversationId int64, recipientIds []int64) error {
 	status := "sent"
 	stmt := `INSERT INTO message_status (messageId, conversationId, recipientId, status) VALUES (?, ?, ?, ?)`
@@ -13,32 +15,98 @@ func (db *appdbimpl) InsertSent(messageId int64, conversationId int64, recipient
 	return nil
 }
 
-func (db *appdbimpl) InsertDelivered(recipientId int64) error {
+// InsertDelivered marks every message sent to recipientId as delivered, and returns the IDs of the conversations
+// where at least one message changed status. The change log gets one entry for each of these conversations.
+func (db *appdbimpl) InsertDelivered(recipientId int64) ([]int64, error) {
 	stmt := `
         UPDATE message_status 
         SET status = 'delivered' 
-        WHERE recipientId = ? AND status = 'sent'`
+        WHERE recipientId = ? AND status = 'sent'
+        RETURNING conversationId, messageId`
 
-	_, err := db.c.Exec(stmt, recipientId)
+	updated, err := db.queryStatusUpdates(stmt, recipientId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	seen := make(map[int64]bool)
+	var conversationIds []int64
+	for _, u := range updated {
+		if seen[u.conversationId] {
+			continue
+		}
+		seen[u.conversationId] = true
+		conversationIds = append(conversationIds, u.conversationId)
+		if err := db.recordChange(u.conversationId, ChangeStatusChanged, nil, &recipientId); err != nil {
+			return nil, err
+		}
+	}
+
+	return conversationIds, nil
 }
 
-func (db *appdbimpl) InsertRead(conversationId int64, recipientId int64) error {
+// InsertRead marks the messages of a conversation delivered to recipientId as read, and reports whether any message
+// changed status. The change log gets one entry for the conversation, however many messages changed.
+func (db *appdbimpl) InsertRead(conversationId int64, recipientId int64) (bool, error) {
 	stmt := `
         UPDATE message_status 
         SET status = 'read' 
         WHERE conversationId = ? 
           AND recipientId = ? 
-          AND status = 'delivered'`
+          AND status = 'delivered'
+        RETURNING conversationId, messageId`
 
-	_, err := db.c.Exec(stmt, conversationId, recipientId)
+	updated, err := db.queryStatusUpdates(stmt, conversationId, recipientId)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	if len(updated) == 0 {
+		return false, nil
+	}
+	if err := db.recordChange(conversationId, ChangeStatusChanged, nil, &recipientId); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CountUnread returns how many messages of a conversation recipientId has not read yet. System messages have no
+// status and never count.
+func (db *appdbimpl) CountUnread(conversationId int64, recipientId int64) (int64, error) {
+	stmt := `SELECT COUNT(*) FROM message_status s
+			 JOIN messages m ON m.id = s.messageId
+			 WHERE s.conversationId = ? AND s.recipientId = ? AND s.status <> 'read'
+			   AND m.kind IN (?, ?, ?) AND m.deletedAt IS NULL AND m.inConversation AND ` + visibleTo
+	var count int64
+	err := db.c.QueryRow(stmt, conversationId, recipientId, KindMessage, KindPoll, KindVoice, recipientId).Scan(&count)
+	return count, err
+}
+
+type statusUpdate struct {
+	conversationId int64
+	messageId      int64
+}
+
+// queryStatusUpdates runs a message_status UPDATE ... RETURNING conversationId, messageId statement and collects the
+// updated rows. The rows are fully read before returning, so the caller can write to the database again.
+func (db *appdbimpl) queryStatusUpdates(stmt string, args ...interface{}) ([]statusUpdate, error) {
+	rows, err := db.c.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer helpers.CloseRows(rows)
+
+	var updated []statusUpdate
+	for rows.Next() {
+		var u statusUpdate
+		if err := rows.Scan(&u.conversationId, &u.messageId); err != nil {
+			return nil, err
+		}
+		updated = append(updated, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return updated, nil
 }
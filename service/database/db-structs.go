@@ -1,17 +1,80 @@
 package database
 
+import "time"
+
 type User struct {
 	UserId   int64
 	Username string
 	Photo    *Photo // optional, can be nil
+	Role     string // role in a conversation, only set in its list of participants
+	JoinedAt string // when the user joined a conversation, only set in its list of participants
+}
+
+type Session struct {
+	SessionId   int64
+	UserId      int64
+	DeviceLabel *string // optional, set by the client at login
+	UserAgent   string
+	IpAddress   string
+	CreatedAt   string
+	ExpiresAt   string
+	LastUsedAt  string
+}
+
+type Credential struct {
+	UserId         int64
+	PasswordHash   string
+	FailedAttempts int
+	LockedUntil    *string
+	IsLocked       bool // true while LockedUntil is in the future
 }
 
 type Conversation struct {
-	ConversationId int64
-	Name           string
-	Participants   []User
-	IsGroup        bool
-	Photo          *Photo
+	ConversationId    int64
+	Name              string
+	Participants      []User
+	IsGroup           bool
+	Photo             *Photo
+	MessageTtl        *int64 // seconds new messages last before disappearing, nil if they do not
+	Permissions       GroupPermissions
+	HistoryVisibility string // HistoryFull or HistorySinceJoining
+}
+
+// Roles of the participants of a group. The participants of private conversations are all members.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Settings of who may perform an action in a group.
+const (
+	AllowMembers = "members" // every participant
+	AllowAdmins  = "admins"  // the owner and the admins
+)
+
+// Settings of which messages the participants of a group see, enforced by visibleTo.
+const (
+	HistoryFull         = "full"   // all of them
+	HistorySinceJoining = "joined" // only the ones sent after they last joined
+)
+
+// GroupPermissions holds who may perform each of the restricted actions in a group, AllowMembers or AllowAdmins.
+type GroupPermissions struct {
+	Rename      string
+	ChangePhoto string
+	AddMembers  string
+	Pin         string
+}
+
+// IsAdmin reports whether a participant with the given role administers the group.
+func IsAdmin(role string) bool {
+	return role == RoleOwner || role == RoleAdmin
+}
+
+// Allows reports whether a participant with the given role may perform an action with the given setting.
+func Allows(setting string, role string) bool {
+	return setting == AllowMembers || IsAdmin(role)
 }
 
 type ReactionView struct {
@@ -26,14 +89,208 @@ type MessageView struct {
 	ConversationId int64
 	Text           *string
 	Timestamp      string
+	EditedAt       *string // time of the last edit, nil if never edited
+	Deleted        bool    // deleted for everyone: only the sender and the timestamp are left
+	ExpiresAt      *string // when the message disappears, nil if it does not
+	Kind           string  // KindMessage, KindPoll, KindVoice, or the kind of system message
+	System         *SystemData
+	Poll           *Poll // set for messages of kind KindPoll
 	Photo          *Photo
+	File           *File
 	Reactions      []ReactionView // aggregated reactions from rows sharing the same messageId
+	Mentions       []Mention      // users mentioned in the text, in order of appearance
 	ReplyTo        *int64
-	Status         string // e.g., "sent", "delivered", "read"
-	IsForwarded    bool   // indicates if the message was forwarded
+	ThreadRootId   *int64  // the thread the message was posted in, nil if none
+	InConversation bool    // false for thread replies that only appear in their thread
+	Pinned         bool    // pinned in its conversation
+	ThreadReplies  int64   // number of replies in the thread started by the message
+	LastReplyAt    *string // time of the latest reply in the thread started by the message, nil if none
+	Status         string  // e.g., "sent", "delivered", "read"
+	IsForwarded    bool    // indicates if the message was forwarded
+}
+
+type UserEvent struct {
+	Seq            int64 // position in the per-user event sequence
+	Type           string
+	ConversationId int64
+	Payload        *string // JSON encoded, optional
+	CreatedAt      string
+}
+
+type Change struct {
+	ChangeId       int64
+	ConversationId int64
+	Kind           string // one of the Change* constants
+	MessageId      *int64 // set for message and reaction changes
+	UserId         *int64 // the user affected, if any
+	Timestamp      string
 }
 
 type Photo struct {
 	PhotoId string
 	Path    string
 }
+
+// File is an attachment other than a photo. Filename is the name it was uploaded with, while Path locates it on disk.
+type File struct {
+	FileId     string
+	Path       string
+	Filename   string
+	Size       int64
+	MimeType   string
+	UploadedBy int64
+	DurationMs *int64 // set for voice notes only, like Waveform
+	Waveform   []byte
+}
+
+// IsVoiceNote reports whether the file was uploaded as a voice note.
+func (f *File) IsVoiceNote() bool {
+	return f.DurationMs != nil
+}
+
+type MessageRevision struct {
+	Text      *string
+	Timestamp string // when this version of the message was written
+}
+
+type ScheduledMessage struct {
+	ScheduledId    int64
+	ConversationId int64
+	SenderId       int64
+	Text           *string
+	Photo          *Photo
+	File           *File
+	ReplyTo        *int64
+	ThreadRootId   *int64
+	InConversation bool
+	SendAt         string
+	CreatedAt      string
+}
+
+// Kinds of messages. Every kind other than KindMessage, KindPoll and KindVoice is a system message, recording a change
+// made by its sender.
+const (
+	KindMessage        = "message"
+	KindPoll           = "poll"
+	KindVoice          = "voice"
+	KindTtlChanged     = "ttl.changed"
+	KindPinned         = "message.pinned"
+	KindAdded          = "member.added"
+	KindJoined         = "member.joined"
+	KindLeft           = "member.left"
+	KindRemoved        = "member.removed"
+	KindRenamed        = "group.renamed"
+	KindHistoryChanged = "history.changed"
+)
+
+// IsSystemKind reports whether messages of the given kind are system messages.
+func IsSystemKind(kind string) bool {
+	return kind != KindMessage && kind != KindPoll && kind != KindVoice
+}
+
+// SystemData holds the details of a system message. Which fields are set depends on the kind.
+type SystemData struct {
+	MessageTtl        *int64  `json:"messageTtl,omitempty"`        // ttl.changed: the new setting, nil when turned off
+	MessageId         *int64  `json:"messageId,omitempty"`         // message.pinned: the pinned message
+	UserId            *int64  `json:"userId,omitempty"`            // member.added and member.removed: the added or removed member
+	Banned            bool    `json:"banned,omitempty"`            // member.removed: whether they were banned from the group too
+	Name              *string `json:"name,omitempty"`              // group.renamed: the new name
+	HistoryVisibility *string `json:"historyVisibility,omitempty"` // history.changed: the new setting
+
+	User *User `json:"-"` // the user UserId refers to, loaded with the message
+}
+
+// Mention is a reference to a user in the text of a message, spanning Length characters (Unicode code points) from
+// Position.
+type Mention struct {
+	User     User
+	Position int
+	Length   int
+}
+
+// SearchFilter narrows a message search. Nil fields do not filter.
+type SearchFilter struct {
+	ConversationId *int64
+	SenderId       *int64
+	Before         *time.Time // only messages sent before this time
+}
+
+type SearchHit struct {
+	Message MessageView
+	Snippet string // excerpt of the content, with the matches between HighlightStart and HighlightEnd
+}
+
+// HighlightStart and HighlightEnd delimit the matched terms in a SearchHit snippet.
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// Ban keeps a user who was removed from a group from being added back.
+type Ban struct {
+	User     User
+	BannedBy User
+	BannedAt string
+}
+
+// NewInvite holds what an admin chooses when creating an invite link.
+type NewInvite struct {
+	ExpiresAt        *time.Time // nil if the link does not expire
+	MaxUses          *int64     // nil if the link can be used any number of times
+	RequiresApproval bool
+}
+
+// Invite is a link through which users can join a group, or ask to join it when RequiresApproval is set.
+type Invite struct {
+	Code             string
+	ConversationId   int64
+	CreatedBy        User
+	CreatedAt        string
+	ExpiresAt        *string
+	MaxUses          *int64
+	Uses             int64
+	RequiresApproval bool
+}
+
+// JoinRequest is a pending request to join a group through an invite link that requires approval.
+type JoinRequest struct {
+	User        User
+	InviteCode  string
+	RequestedAt string
+}
+
+type Pin struct {
+	Message  MessageView
+	PinnedBy User
+	PinnedAt string
+}
+
+// NewPoll holds what the sender chooses when creating a poll.
+type NewPoll struct {
+	Question       string
+	Options        []string
+	MultipleChoice bool
+	Anonymous      bool
+	ClosesAt       *time.Time // nil if the poll stays open
+}
+
+// Poll holds the settings and aggregated results of a poll message.
+type Poll struct {
+	MultipleChoice bool
+	Anonymous      bool
+	ClosesAt       *string // nil if the poll stays open
+	Closed         bool
+	Options        []PollOption
+	VoterCount     int // users who voted for at least one option
+}
+
+type PollOption struct {
+	Text   string
+	Votes  int
+	Voters []User // nil for anonymous polls
+}
+
+type ExpiredMessage struct {
+	MessageId      int64
+	ConversationId int64
+}
@@ -89,7 +89,7 @@ func (db *appdbimpl) UpdateUsername(username string, id int64) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return db.recordUserChange(id)
 }
 
 func (db *appdbimpl) UpdateUserPhoto(photoId string, id int64) error {
@@ -98,7 +98,7 @@ func (db *appdbimpl) UpdateUserPhoto(photoId string, id int64) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return db.recordUserChange(id)
 }
 
 func (db *appdbimpl) GetUsersByName(usernames []string) ([]User, error) {
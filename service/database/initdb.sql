@@ -13,6 +13,14 @@ CREATE TABLE IF NOT EXISTS "conversations" (
     name TEXT NOT NULL,
     isGroup BOOLEAN NOT NULL,
     photoId TEXT,
+    messageTtl INTEGER,
+    -- Who may change a group: 'members' lets every participant, 'admins' only the owner and the admins
+    renamePermission TEXT NOT NULL DEFAULT 'members' CHECK (renamePermission IN ('members', 'admins')),
+    photoPermission TEXT NOT NULL DEFAULT 'members' CHECK (photoPermission IN ('members', 'admins')),
+    addPermission TEXT NOT NULL DEFAULT 'members' CHECK (addPermission IN ('members', 'admins')),
+    pinPermission TEXT NOT NULL DEFAULT 'members' CHECK (pinPermission IN ('members', 'admins')),
+    -- Which messages new members of a group see: 'full' all of them, 'joined' only the ones sent after they joined
+    historyVisibility TEXT NOT NULL DEFAULT 'full' CHECK (historyVisibility IN ('full', 'joined')),
     FOREIGN KEY (photoId) REFERENCES images(uuid)
 );
 
@@ -22,16 +30,133 @@ CREATE TABLE IF NOT EXISTS "messages" (
     conversationId INTEGER NOT NULL,
     content TEXT,
     photoId TEXT,
+    fileId TEXT,
     replyTo INTEGER,
+    threadRootId INTEGER,
+    inConversation BOOLEAN NOT NULL DEFAULT TRUE,
     isForwarded BOOLEAN DEFAULT FALSE,
     timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    editedAt DATETIME,
+    deletedAt DATETIME,
+    expiresAt DATETIME,
+    kind TEXT NOT NULL DEFAULT 'message',
+    systemData TEXT,
     FOREIGN KEY (senderId) REFERENCES users(id),
     FOREIGN KEY (conversationId) REFERENCES conversations(id),
     FOREIGN KEY (photoId) REFERENCES images(uuid),
+    FOREIGN KEY (fileId) REFERENCES files(uuid),
     FOREIGN KEY (replyTo) REFERENCES messages(id) ON DELETE SET NULL,
-    CHECK (content IS NOT NULL OR photoId IS NOT NULL)
+    FOREIGN KEY (threadRootId) REFERENCES messages(id) ON DELETE SET NULL,
+    CHECK (content IS NOT NULL OR photoId IS NOT NULL OR fileId IS NOT NULL OR deletedAt IS NOT NULL OR kind <> 'message'),
+    CHECK (threadRootId IS NOT NULL OR inConversation)
 );
 
+CREATE INDEX IF NOT EXISTS "messages_conversationId" ON messages (conversationId, id);
+CREATE INDEX IF NOT EXISTS "messages_threadRootId" ON messages (threadRootId, id) WHERE threadRootId IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "messages_fileId" ON messages (fileId) WHERE fileId IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "messages_expiresAt" ON messages (expiresAt) WHERE expiresAt IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS "scheduled_messages" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversationId INTEGER NOT NULL,
+    senderId INTEGER NOT NULL,
+    content TEXT,
+    photoId TEXT,
+    fileId TEXT,
+    replyTo INTEGER,
+    threadRootId INTEGER,
+    inConversation BOOLEAN NOT NULL DEFAULT TRUE,
+    sendAt DATETIME NOT NULL,
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (senderId) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (photoId) REFERENCES images(uuid),
+    FOREIGN KEY (fileId) REFERENCES files(uuid),
+    FOREIGN KEY (replyTo) REFERENCES messages(id) ON DELETE SET NULL,
+    FOREIGN KEY (threadRootId) REFERENCES messages(id) ON DELETE SET NULL,
+    CHECK (content IS NOT NULL OR photoId IS NOT NULL OR fileId IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS "scheduled_messages_sendAt" ON scheduled_messages (sendAt);
+
+CREATE TABLE IF NOT EXISTS "hidden_messages" (
+    userId INTEGER NOT NULL,
+    messageId INTEGER NOT NULL,
+    PRIMARY KEY (userId, messageId),
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (messageId) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "pins" (
+    messageId INTEGER PRIMARY KEY,
+    conversationId INTEGER NOT NULL,
+    pinnedBy INTEGER NOT NULL,
+    pinnedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (messageId) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (pinnedBy) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS "pins_conversationId" ON pins (conversationId, pinnedAt);
+
+-- Settings of the messages of kind 'poll', whose content is the question
+CREATE TABLE IF NOT EXISTS "polls" (
+    messageId INTEGER PRIMARY KEY,
+    multipleChoice BOOLEAN NOT NULL DEFAULT FALSE,
+    anonymous BOOLEAN NOT NULL DEFAULT FALSE,
+    closesAt DATETIME,
+    FOREIGN KEY (messageId) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "poll_options" (
+    messageId INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    PRIMARY KEY (messageId, position),
+    FOREIGN KEY (messageId) REFERENCES polls(messageId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "poll_votes" (
+    messageId INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    userId INTEGER NOT NULL,
+    votedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (messageId, userId, position),
+    FOREIGN KEY (messageId, position) REFERENCES poll_options(messageId, position) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "starred_messages" (
+    userId INTEGER NOT NULL,
+    messageId INTEGER NOT NULL,
+    starredAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (userId, messageId),
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (messageId) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "mentions" (
+    messageId INTEGER NOT NULL,
+    userId INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    length INTEGER NOT NULL,
+    PRIMARY KEY (messageId, position),
+    FOREIGN KEY (messageId) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "mentions_userId" ON mentions (userId, messageId);
+
+CREATE TABLE IF NOT EXISTS "message_revisions" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    messageId INTEGER NOT NULL,
+    content TEXT,
+    timestamp DATETIME NOT NULL,
+    FOREIGN KEY (messageId) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "message_revisions_messageId" ON message_revisions (messageId, id);
+
 CREATE TABLE IF NOT EXISTS "message_status" (
     messageId INTEGER NOT NULL,
     conversationId INTEGER NOT NULL,
@@ -59,12 +184,125 @@ CREATE TABLE IF NOT EXISTS "reactions" (
 CREATE TABLE IF NOT EXISTS "participants" (
     userId INTEGER NOT NULL,
     conversationId INTEGER NOT NULL,
+    -- Only groups have an owner and admins
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+    -- When the user last joined; users who left keep their row, with leftAt set, until they are added back
+    joinedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    leftAt DATETIME,
     FOREIGN KEY (userId) REFERENCES users(id),
     FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE
 );
 
+-- Users removed from a group who cannot be added back until their ban is lifted
+CREATE TABLE IF NOT EXISTS "group_bans" (
+    conversationId INTEGER NOT NULL,
+    userId INTEGER NOT NULL,
+    bannedBy INTEGER NOT NULL,
+    bannedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversationId, userId),
+    FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (bannedBy) REFERENCES users(id)
+);
+
+-- Links through which users join a group by themselves, until they expire, run out of uses or are revoked
+CREATE TABLE IF NOT EXISTS "invites" (
+    code TEXT PRIMARY KEY,
+    conversationId INTEGER NOT NULL,
+    createdBy INTEGER NOT NULL,
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expiresAt DATETIME,
+    maxUses INTEGER,
+    uses INTEGER NOT NULL DEFAULT 0,
+    -- Whether joining through the link only creates a join request, which an admin approves or denies
+    requiresApproval BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (createdBy) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS "invites_conversationId" ON invites (conversationId);
+
+CREATE TABLE IF NOT EXISTS "join_requests" (
+    conversationId INTEGER NOT NULL,
+    userId INTEGER NOT NULL,
+    inviteCode TEXT NOT NULL,
+    requestedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversationId, userId),
+    FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS "images" (
     uuid TEXT PRIMARY KEY,
     path TEXT NOT NULL
 );
 
+-- Attachments other than photos, which are only served to the participants of the conversations they are sent to
+CREATE TABLE IF NOT EXISTS "files" (
+    uuid TEXT PRIMARY KEY,
+    path TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    mimeType TEXT NOT NULL,
+    uploadedBy INTEGER NOT NULL,
+    uploadedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    -- Set for voice notes only
+    durationMs INTEGER,
+    waveform BLOB,
+    FOREIGN KEY (uploadedBy) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tokenHash TEXT NOT NULL UNIQUE,
+    userId INTEGER NOT NULL,
+    deviceLabel TEXT,
+    userAgent TEXT,
+    ipAddress TEXT,
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expiresAt DATETIME NOT NULL,
+    lastUsedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "credentials" (
+    userId INTEGER PRIMARY KEY,
+    passwordHash TEXT NOT NULL,
+    failedAttempts INTEGER NOT NULL DEFAULT 0,
+    lockedUntil DATETIME,
+    updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "event_sequences" (
+    userId INTEGER PRIMARY KEY,
+    lastSeq INTEGER NOT NULL,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "user_events" (
+    userId INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    conversationId INTEGER NOT NULL,
+    payload TEXT,
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (userId, seq),
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "user_events_createdAt" ON user_events (createdAt);
+
+CREATE TABLE IF NOT EXISTS "changes" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversationId INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    messageId INTEGER,
+    userId INTEGER,
+    -- Set for the changes that only this user receives, like the messages they hid
+    forUserId INTEGER,
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS "changes_conversationId" ON changes (conversationId, id);
+
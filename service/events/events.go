@@ -0,0 +1,170 @@
+/*
+Package events contains the in-process event bus used to push real-time updates (new messages, reactions, membership
+changes, ...) to connected clients.
+
+Handlers publish an Event to each user it concerns; every open client connection of that user holds a Subscription
+and receives it on its channel. Delivery is best effort: a subscriber that does not keep up is dropped (its channel is
+closed) and is expected to reconnect and catch up on what it missed, using the event Id.
+*/
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Reewd/WASAproject/service/globaltime"
+)
+
+// Type identifies the kind of an Event.
+type Type string
+
+const (
+	MessageCreated      Type = "message.created"
+	MessageEdited       Type = "message.edited"
+	MessageDeleted      Type = "message.deleted"
+	ReactionChanged     Type = "reaction.changed"
+	ThreadUpdated       Type = "thread.updated"
+	PinChanged          Type = "pin.changed"
+	PollChanged         Type = "poll.changed"
+	StarChanged         Type = "star.changed"
+	ParticipantAdded    Type = "participant.added"
+	ParticipantRemoved  Type = "participant.removed"
+	JoinRequested       Type = "join_request.created"
+	JoinRequestResolved Type = "join_request.resolved"
+	GroupRenamed        Type = "group.renamed"
+	RoleChanged         Type = "role.changed"
+	PermissionsChanged  Type = "permissions.changed"
+	StatusUpdated       Type = "status.updated"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before being dropped.
+const subscriberBuffer = 64
+
+// Event is a typed notification sent to clients. Payload is serialized as JSON as-is.
+type Event struct {
+	Id             int64       `json:"id"` // position in the recipient's event sequence
+	Type           Type        `json:"type"`
+	ConversationId int64       `json:"conversationId"`
+	Timestamp      string      `json:"timestamp"`
+	Payload        interface{} `json:"payload,omitempty"`
+}
+
+// Bus dispatches events to the subscriptions of each user.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription receives the events published to one user. C is closed when the subscription ends, either because of
+// Close, because the bus was closed, because the session that opened it was revoked or because the subscriber was too
+// slow.
+type Subscription struct {
+	C <-chan Event
+
+	ch        chan Event
+	userId    int64
+	sessionId int64
+	bus       *Bus
+}
+
+// NewBus returns an empty, ready to use Bus.
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a new subscription for userId, opened by the session sessionId. If the bus is already closed,
+// the returned subscription channel is closed too.
+func (b *Bus) Subscribe(userId int64, sessionId int64) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, userId: userId, sessionId: sessionId, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return sub
+	}
+
+	if b.subscribers[userId] == nil {
+		b.subscribers[userId] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userId][sub] = struct{}{}
+	return sub
+}
+
+// Publish sends ev to every subscription of userId. It never blocks.
+func (b *Bus) Publish(userId int64, ev Event) {
+	if ev.Timestamp == "" {
+		ev.Timestamp = globaltime.Now().UTC().Format(time.RFC3339)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	for sub := range b.subscribers[userId] {
+		select {
+		case sub.ch <- ev:
+		default:
+			// The subscriber is not keeping up: drop it
+			b.remove(sub)
+		}
+	}
+}
+
+// EndSessions ends the subscriptions of userId opened by the sessions for which revoked returns true, so that
+// logged out devices stop receiving events.
+func (b *Bus) EndSessions(userId int64, revoked func(sessionId int64) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[userId] {
+		if revoked(sub.sessionId) {
+			b.remove(sub)
+		}
+	}
+}
+
+// Close ends every subscription. Publish and Subscribe can still be called, but they have no effect.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for _, subs := range b.subscribers {
+		for sub := range subs {
+			close(sub.ch)
+		}
+	}
+	b.subscribers = nil
+}
+
+// Close ends the subscription. It is safe to call it more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subscribers[s.userId][s]; ok {
+		s.bus.remove(s)
+	}
+}
+
+// remove unregisters sub and closes its channel. The caller must hold b.mu.
+func (b *Bus) remove(sub *Subscription) {
+	delete(b.subscribers[sub.userId], sub)
+	if len(b.subscribers[sub.userId]) == 0 {
+		delete(b.subscribers, sub.userId)
+	}
+	close(sub.ch)
+}
@@ -1,7 +1,7 @@
 import { ref, computed } from 'vue'
 
 const user = ref(JSON.parse(localStorage.getItem('loggedInUser') || 'null'))
-const isLoggedIn = computed(() => user.value !== null)
+const isLoggedIn = computed(() => user.value !== null && !!user.value.token)
 
 function login(userInfo) {
   localStorage.setItem('loggedInUser', JSON.stringify(userInfo))
@@ -61,7 +61,7 @@ export function usePhotoUpload() {
       const response = await axios.post('/upload', formData, {
         headers: {
           'Content-Type': 'multipart/form-data',
-          Authorization: user.value.userId,
+          Authorization: `Bearer ${user.value.token}`,
         },
       })
       return response.data